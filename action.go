@@ -0,0 +1,56 @@
+package autoflags
+
+import (
+	"flag"
+	"strconv"
+)
+
+// DefineAction defines on fs a boolean flag which, when set, makes
+// [RunActions] call fn. It is intended for flags like -version that print
+// something and exit instead of doing actual work:
+//
+//	autoflags.DefineAction(flag.CommandLine, "version", "print version and exit",
+//		func() { fmt.Println(version) })
+//	flag.Parse()
+//	if autoflags.RunActions(flag.CommandLine) {
+//		os.Exit(0)
+//	}
+func DefineAction(fs *flag.FlagSet, name, usage string, fn func()) {
+	fs.Var(&actionValue{fn: fn}, name, usage)
+}
+
+// RunActions is supposed to be called after fs is parsed, it calls functions
+// of all action flags (see [DefineAction]) that were set, in lexicographical
+// order of flag names. It reports whether any action was run.
+func RunActions(fs *flag.FlagSet) bool {
+	var ran bool
+	fs.Visit(func(f *flag.Flag) {
+		if v, ok := f.Value.(*actionValue); ok && v.set && v.fn != nil {
+			v.fn()
+			ran = true
+		}
+	})
+	return ran
+}
+
+// actionValue is a flag.Value for flags defined by DefineAction
+type actionValue struct {
+	fn  func()
+	set bool
+}
+
+func (v *actionValue) IsBoolFlag() bool { return true }
+func (v *actionValue) String() string {
+	if v == nil {
+		return "false"
+	}
+	return strconv.FormatBool(v.set)
+}
+func (v *actionValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	v.set = b
+	return nil
+}
@@ -0,0 +1,30 @@
+package autoflags
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestActions(t *testing.T) {
+	var version, license int
+	newFlagSet := func() *flag.FlagSet {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		DefineAction(fs, "version", "print version", func() { version++ })
+		DefineAction(fs, "license", "print license", func() { license++ })
+		return fs
+	}
+	fs := newFlagSet()
+	if err := fs.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	if RunActions(fs) {
+		t.Fatal("no action should have been run")
+	}
+	fs = newFlagSet()
+	if err := fs.Parse([]string{"-version", "-license=false"}); err != nil {
+		t.Fatal(err)
+	}
+	if !RunActions(fs) || version != 1 || license != 0 {
+		t.Fatalf("unexpected result: version=%d, license=%d", version, license)
+	}
+}
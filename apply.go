@@ -0,0 +1,89 @@
+package autoflags
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Apply takes pointer to a struct and sets its flag-tagged fields from values
+// which map flag names to their string representation, as they would be given
+// on the command line. Keys that don't correspond to any flag are ignored.
+//
+// Apply is meant to populate config from sources like configuration files or
+// environment; call it before [Define] so that applied values become defaults
+// that command line flags can still override. Flags for config are defined
+// the same way [Define] does, so defaults given by default and defaultfrom
+// options are applied to its fields too.
+func Apply(config interface{}, values map[string]string) error {
+	return apply(config, values, false)
+}
+
+// ApplyStrict works like [Apply], but reports an error listing keys of values
+// not corresponding to any flag. No fields are set in this case.
+func ApplyStrict(config interface{}, values map[string]string) error {
+	return apply(config, values, true)
+}
+
+func apply(config interface{}, values map[string]string, strict bool) error {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if strict {
+		unknown, err := unknownFlags(config, keys)
+		if err != nil {
+			return err
+		}
+		if len(unknown) != 0 {
+			return fmt.Errorf("autoflags: unknown flags: %s", strings.Join(unknown, ", "))
+		}
+	}
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	defer Forget(fs)
+	if err := defaultDefiner.defineFlagSet(fs, config, false); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if fs.Lookup(k) == nil {
+			continue
+		}
+		if err := setLenient(fs, k, values[k]); err != nil {
+			return fmt.Errorf("autoflags: invalid value %q for flag %s: %w", censoredArg(fs, k, values[k]), k, err)
+		}
+	}
+	return nil
+}
+
+// unknownFlags returns those of names that don't correspond to flags of
+// config; flags are defined for a copy of config, so config is not modified
+func unknownFlags(config interface{}, names []string) ([]string, error) {
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	defer Forget(fs)
+	if err := defaultDefiner.defineFlagSet(fs, deepCopy(config), false); err != nil {
+		return nil, err
+	}
+	var unknown []string
+	for _, name := range names {
+		if fs.Lookup(name) == nil {
+			unknown = append(unknown, name)
+		}
+	}
+	return unknown, nil
+}
+
+// setLenient sets flag of fs for non-command line sources of values, which are
+// more lenient: boolean flags accept arguments understood by parseBool.
+func setLenient(fs *flag.FlagSet, name, value string) error {
+	if f := fs.Lookup(name); f != nil && isBoolFlag(f) {
+		b, err := parseBool(value)
+		if err != nil {
+			return err
+		}
+		value = strconv.FormatBool(b)
+	}
+	return fs.Set(name, value)
+}
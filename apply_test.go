@@ -0,0 +1,64 @@
+package autoflags
+
+import (
+	"testing"
+	"time"
+)
+
+func TestApply(t *testing.T) {
+	conf := struct {
+		Name    string        `flag:"name"`
+		Timeout time.Duration `flag:"timeout"`
+	}{Name: "John Doe"}
+	values := map[string]string{"timeout": "1m", "extra": "value"}
+	if err := ApplyStrict(&conf, values); err == nil || err.Error() != "autoflags: unknown flags: extra" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if conf.Timeout != 0 {
+		t.Fatal("ApplyStrict should not set fields if there are unknown keys")
+	}
+	if err := Apply(&conf, values); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Name != "John Doe" || conf.Timeout != time.Minute {
+		t.Fatalf("unexpected result: %+v", conf)
+	}
+	if err := Apply(&conf, map[string]string{"timeout": "soon"}); err == nil {
+		t.Fatal("applying invalid value should fail")
+	}
+}
+
+func TestApplyBool(t *testing.T) {
+	conf := struct {
+		Debug   bool `flag:"debug"`
+		Verbose bool `flag:"verbose"`
+	}{Verbose: true}
+	if err := Apply(&conf, map[string]string{"debug": "yes", "verbose": "0"}); err != nil {
+		t.Fatal(err)
+	}
+	if !conf.Debug || conf.Verbose {
+		t.Fatalf("unexpected result: %+v", conf)
+	}
+	if err := Apply(&conf, map[string]string{"debug": "maybe"}); err == nil {
+		t.Fatal("applying invalid value should fail")
+	}
+}
+
+func TestApplyStrictUnmodified(t *testing.T) {
+	conf := struct {
+		Dir   string `flag:"dir"`
+		Cache string `flag:"cache,,defaultfrom=Dir+/cache"`
+	}{Dir: "/var"}
+	if err := ApplyStrict(&conf, map[string]string{"bogus": "1"}); err == nil {
+		t.Fatal("ApplyStrict should fail on unknown keys")
+	}
+	if conf.Cache != "" {
+		t.Fatalf("ApplyStrict applied defaults despite an error: %+v", conf)
+	}
+	if err := ApplyStrict(&conf, map[string]string{"dir": "/srv"}); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Dir != "/srv" || conf.Cache != "/var/cache" {
+		t.Fatalf("unexpected result: %+v", conf)
+	}
+}
@@ -24,16 +24,181 @@
 //
 // Package autoflags understands all basic types supported by the [flag] package
 // xxxVar functions: int, int64, uint, uint64, float64, bool, string,
-// time.Duration. Types implementing [flag.Value] interface are also supported.
+// time.Duration. Types implementing [flag.Value] interface are also supported,
+// as well as types implementing [encoding.TextUnmarshaler], like time.Time or
+// net.IP; defaults of the latter are shown using their MarshalText method, if
+// they implement [encoding.TextMarshaler].
+//
+// Besides that, the following field types are supported:
+//
+//   - net.IPNet taking CIDR notation like "192.168.0.0/16";
+//   - net.HardwareAddr, or slices of them, taking MAC addresses in any form
+//     understood by [net.ParseMAC], like "00:11:22:33:44:55";
+//   - maps with string keys and string, int, float64, bool, time.Duration
+//     or net.IPNet values, populated from repeated key=value flags; for
+//     bool values, bare key is the same as key=true;
+//   - maps with string keys and slices of the same types as values,
+//     populated from repeated "key: value" flags, values of repeated keys are
+//     appended to the slice;
+//   - slices of strings, ints, uints, uint64, float64, bools, time.Duration
+//     or net.IPNet taking comma-separated lists of elements; repeated flags
+//     append to the slice, though the first one replaces any default value;
+//     empty argument results in no elements, so it can be used to clear the
+//     default;
+//   - slices of structs with two string fields named Key and Value, like
+//     []struct{ Key, Value string }, taking comma-separated lists of
+//     key=value pairs; unlike maps, they keep order of pairs and repeated
+//     keys;
+//   - arrays of the same element types taking comma-separated lists of
+//     exactly as many elements as the array length;
+//   - *time.Location taking location names like "America/New_York" understood
+//     by [time.LoadLocation];
+//   - interface types having implementations registered with
+//     [RegisterInterfaceImpl], taking names of implementations;
+//   - json.RawMessage taking well-formed JSON documents as is;
+//   - time.Weekday and time.Month, or slices of them, taking either numbers
+//     or English names, full or abbreviated, in any case, like Monday or jan.
+//
 // Attaching a non-empty `flag` tag to field of an unsupported type would result in
 // panic.
+//
+// Untagged struct fields, or non-nil pointers to structs, are walked
+// recursively, so their flag-tagged fields are exposed as flags too. Such
+// flags are grouped under the name of the struct field for [PrintGrouped],
+// unless their group is set explicitly.
+//
+// Tags may list options after the usage string, separated by commas:
+//
+//	Token string `flag:"token,auth token,fromfile"`
+//
+// Values of sep, split and pattern options may have commas: text following
+// them up to the next known option is a part of the value, so that
+// `flag:"tags,,sep=,,unique"` splits arguments around commas.
+//
+// Supported options are:
+//
+//	short=x		flag can also be set by the given short name
+//	alias=name	flag can also be set by the given name, which is not
+//			listed by [PrintDefaults]
+//	deprecated=msg	flag is deprecated, or its alias if it has one; see
+//			[WarnDeprecated]
+//	required	flag must be set, see [CheckRequired]
+//	env=NAME	flag can be set from the given environment variable, see
+//			[ApplyEnv] and [Resolve]
+//	fromfile	string field only; flag argument is a name of the file to
+//			read value from, single trailing newline is trimmed
+//	fromfile=path	same as fromfile; if flag is not set, [Resolve] reads
+//			value from the given file
+//	prompt		string field; if argument is "-", value is read from the
+//			terminal with echo disabled, like a password; fails if
+//			standard input is not a terminal
+//	levels=debug:0|info:1
+//			signed integer field; argument is either one of the listed
+//			level names, matched case-insensitively, or a number; level
+//			name is shown for the default value
+//	bits=a:1|b:2	unsigned integer field; argument is a comma-separated list
+//			of bit names, like a,b, which values are ORed together;
+//			repeated flags add bits, though the first one replaces
+//			default value
+//	invert		bool field; flag has the opposite meaning of the field, so
+//			that for DisableCache field -cache=false sets it to true
+//	humanbool	bool field; besides values accepted by [strconv.ParseBool],
+//			argument may be yes, no, on or off in any case; values
+//			set by [Apply], [ApplyEnv] and [LoadKV] are always
+//			parsed this way
+//	template	string field; argument is a [text/template] executed
+//			against environment variables, like "Hello {{.USER}}",
+//			and the field is set to its output; referencing unset
+//			variable is an error
+//	text		[]byte field; argument is stored as is, except that Go
+//			escape sequences like \t or \n are decoded; value is
+//			shown as a quoted string
+//	defaultunit=s	time.Duration field; argument without unit, like 30, is
+//			interpreted in the given unit (ns, us, ms, s, m or h);
+//			arguments with unit are parsed as usual
+//	bytesize	uint64 or int64 field; value is a size in bytes with
+//			optional suffix: KB, MB, GB, TB, PB for powers of 1000
+//			or KiB, MiB, GiB, TiB, PiB for powers of 1024, like
+//			10MiB; int64 fields accept negative sizes, like -1.5GB
+//	bitrate		uint64 or int64 field; value is a data rate in bits per
+//			second given with bps, Kbps, Mbps or Gbps suffix, like
+//			10Mbps; it can't be negative
+//	sep=separator	slice or array field; separator to split argument into elements
+//			instead of comma
+//	fields		slice or array field; argument is split into elements around runs
+//			of white space, can't be used with sep option
+//	split=regexp	slice or array field; argument is split into elements
+//			around matches of the regular expression, can't be used
+//			with sep or fields options; double backslashes as tag
+//			values are Go strings: `flag:"tokens,,split=\\s*,\\s*"`
+//	emptyutc	*time.Location field; empty argument means UTC instead of
+//			being an error
+//	when=Field	flag is only defined if bool field with the given name
+//			is true at the time of definition
+//	default=value	flag default if field has zero value at the time of
+//			definition; such default is shown by [PrintDefaults]
+//			even if it is the zero value
+//	defaultfrom=Field+suffix
+//			string field; if field is empty, its default is the value
+//			of string field with the given name at the time of
+//			definition, followed by optional suffix, like
+//			defaultfrom=DataDir+/backup
+//	oneof=a|b|c	string or float64 field, or slice or array of them; value
+//			must be one of the listed ones; float64 values are
+//			compared with a tolerance set by epsilon option (1e-9 by
+//			default), and default value must also be one of them
+//	epsilon=x	float64 field with oneof option; comparison tolerance
+//	pattern=regexp	string field, or slice or array of strings; value must
+//			match the regular expression, like pattern=^[a-z0-9.-]+$;
+//			non-empty default value must match it too
+//	nonempty	string field, or slice or array of strings; value must
+//			not be empty or consist of white space only; unlike
+//			required, it only validates value when flag is set
+//	kvsep=sep	map field; separator of key and value instead of "=", or
+//			":" for maps with slice values
+//	pairs		map field; argument is a comma-separated list of key/value
+//			pairs, like fast=100ms,slow=2s
+//	unique		slice field; elements already present are not added
+//			again
+//	sorted-set	slice of strings or ints; slice is kept sorted and
+//			deduplicated
+//	maxlen=n	slice field; setting flag fails if slice would have more
+//			than n elements
+//	minlen=n	slice field; slice must have at least n elements, which
+//			is verified by [CheckMinLen] or [Verify] after parsing
+//	maxtotal=d	slice of time.Duration; sum of durations must not exceed
+//			d, like 5m, which is verified by [Verify] after parsing
+//	nodefaultvalue	value must differ from the default, which is verified by
+//			[Verify] after parsing
+//	once		flag can't be repeated, setting it again is an error
+//	history		arguments flag is set to are recorded, see [History]
+//	censor		value is masked wherever this package outputs it: in
+//			flag defaults, including Flag.DefValue, in [Flags],
+//			[Describe], [WriteMarkdown], [SaveJSON] and [History], in
+//			rejected arguments reported by this package parse and
+//			apply functions, like [ParseWithFieldErrors] or
+//			[ApplyEnv]; the field itself holds the real value. Note
+//			that flag.FlagSet.Parse reports rejected arguments as is,
+//			[Parse] and other parse functions of this package don't
+//	hidden		flag is not listed by this package help output functions,
+//			like [PrintDefaults], still its usage is reported on errors
+//	group=name	flag is listed under the given heading by [PrintGrouped]
+//	exclusive=group	flag belongs to a group of mutually exclusive flags,
+//			see [CheckExclusive]
 package autoflags // import "github.com/artyom/autoflags"
 
 import (
+	"encoding"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"log"
+	"net"
+	"os"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -50,6 +215,31 @@ var (
 	errInvalidField   = errors.New("autoflags: field is of unsupported type")
 )
 
+// UsageFunc, if not nil, is called for each flag defined by this package
+// with flag name, its usage string and metadata, and its result is used as
+// the flag usage string. It allows applying formatting rules to all flags
+// without editing their tags, like mentioning environment variables:
+//
+//	autoflags.UsageFunc = func(name, usage string, info autoflags.FlagInfo) string {
+//		if info.Env != "" {
+//			usage += " (env " + info.Env + ")"
+//		}
+//		return usage
+//	}
+var UsageFunc func(name, usage string, info FlagInfo) string
+
+// DebugLogger, if not nil, is used to log each struct field considered by
+// [DefineFlagSet] and other functions defining flags: whether flag was
+// defined for the field, or why the field was skipped.
+var DebugLogger *log.Logger
+
+// debugf logs a message with DebugLogger. Callers check that DebugLogger is
+// not nil first, so that arguments are not evaluated and boxed when debug
+// logging is off.
+func debugf(format string, args ...interface{}) {
+	DebugLogger.Printf(format, args...)
+}
+
 // Define takes pointer to a struct and declares flags for its flag-tagged fields.
 // Valid tags have one of the following formats:
 //
@@ -66,73 +256,729 @@ func Define(config interface{}) { DefineFlagSet(flag.CommandLine, config) }
 //
 //	autoflags.Define(&args)
 //	flag.Parse()
-func Parse(config interface{}) { Define(config); flag.Parse() }
+func Parse(config interface{}) {
+	Define(config)
+	parseCensored(flag.CommandLine, os.Args[1:])
+}
+
+// ParseWithErrorHandling creates a new FlagSet named after the program with
+// the given error handling mode, declares flags for config on it and parses
+// args. Unlike [Parse], it returns an error instead of panicking if config is
+// invalid. Note that with [flag.ExitOnError] a parse failure calls [os.Exit]
+// and with [flag.PanicOnError] it panics, so the error is only returned for
+// [flag.ContinueOnError]. Args are parsed with [ParseWithFieldErrors], so
+// rejected flag values are reported as [*FieldError]. Call [Forget] once
+// returned FlagSet is no longer used.
+func ParseWithErrorHandling(config interface{}, args []string, h flag.ErrorHandling) (*flag.FlagSet, error) {
+	fs := flag.NewFlagSet(os.Args[0], h)
+	if err := defaultDefiner.defineFlagSet(fs, config, false); err != nil {
+		return fs, err
+	}
+	return fs, ParseWithFieldErrors(fs, args)
+}
+
+// ParseAndValidate works like [ParseWithErrorHandling] with
+// [flag.ContinueOnError] mode, and then calls validate, if it is not nil. This
+// is a place for checks involving multiple fields of config:
+//
+//	_, err := autoflags.ParseAndValidate(&config, os.Args[1:], func() error {
+//		if config.Min > config.Max {
+//			return errors.New("min must not exceed max")
+//		}
+//		return nil
+//	})
+func ParseAndValidate(config interface{}, args []string, validate func() error) (*flag.FlagSet, error) {
+	fs, err := ParseWithErrorHandling(config, args, flag.ContinueOnError)
+	if err != nil || validate == nil {
+		return fs, err
+	}
+	return fs, validate()
+}
 
 // DefineFlagSet takes pointer to a struct and declares flags for its flag-tagged
 // fields on a given FlagSet. Valid tags have one of the following formats:
 //
 //	`flag:"flagname"`
 //	`flag:"flagname,usage string"`
+//	`flag:"flagname,usage string,option,option=value"`
+//
+// Options following usage string alter how the flag is handled, see package
+// documentation for the list of supported options. If text after the second
+// comma is not a list of known options, it is considered to be a part of the
+// usage string.
+//
+// Config may also be a pointer to a pointer to a struct, like **Config given
+// by some frameworks; nil intermediate pointers are set to newly allocated
+// structs.
 //
 // DefineFlagSet panics if given an unsupported/invalid config argument
 // (anything but a non-nil pointer to a struct) or if any config attribute with
 // `flag` tag is of type unsupported by the flag package (consider implementing
 // [flag.Value] interface for such attrubutes).
 func DefineFlagSet(fs *flag.FlagSet, config interface{}) {
+	if err := defaultDefiner.defineFlagSet(fs, config, false); err != nil {
+		panic(err)
+	}
+}
+
+// DefineFlagSetStrict works like [DefineFlagSet], but returns an error instead
+// of panicking. It also performs additional checks of config: unlike
+// DefineFlagSet which silently skips flag-tagged unexported fields,
+// DefineFlagSetStrict reports them as an error. For fields of types
+// implementing [flag.Value], it also verifies that their non-zero defaults
+// round-trip: result of String method, passed to Set method of a zero value,
+// gives the same String result. Tag options which can't be used together, like
+// required and hidden, are reported too.
+func DefineFlagSetStrict(fs *flag.FlagSet, config interface{}) error {
+	return defaultDefiner.defineFlagSet(fs, config, true)
+}
+
+// DefineFlagSetUnique works like [DefineFlagSetStrict], but if a flag name is
+// already defined on fs, or is used by another field of config, it appends
+// a numeric suffix to the name instead of reporting an error: a second
+// "-name" flag becomes "-name2", the third one "-name3" and so on.
+//
+// DefineFlagSetUnique is meant for test harnesses that define flags from
+// the same config multiple times on a shared FlagSet, it should not be used
+// in production code, since resulting flag names depend on definition order.
+func DefineFlagSetUnique(fs *flag.FlagSet, config interface{}) error {
+	fields, err := defaultDefiner.configFields(fs, config, false)
+	if err != nil {
+		return err
+	}
+	uniqueNames(fs, fields)
+	defineFields(fs, fields, config)
+	return nil
+}
+
+// DefineFlagSetWithOverrides works like [DefineFlagSetStrict], but flag values
+// of fields listed in overrides are created by the corresponding functions
+// instead of this package. Overrides are keyed by struct field names,
+// dot-separated for fields of nested structs, like "DB.Password"; functions are
+// called with addressable field values:
+//
+//	err := autoflags.DefineFlagSetWithOverrides(fs, &config,
+//		map[string]func(reflect.Value) flag.Value{
+//			"Password": func(v reflect.Value) flag.Value {
+//				return &promptValue{p: v.Addr().Interface().(*string)}
+//			},
+//		})
+//
+// Tag options affecting how values are parsed don't apply to overridden
+// fields. It is an error if overrides has names of fields without flags.
+func DefineFlagSetWithOverrides(fs *flag.FlagSet, config interface{}, overrides map[string]func(reflect.Value) flag.Value) error {
+	d := *defaultDefiner
+	d.overrides = overrides
+	fields, err := d.configFields(fs, config, true)
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		known[f.name] = true
+	}
+	var unknown []string
+	for name := range overrides {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) != 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("autoflags: overrides for unknown fields: %s", strings.Join(unknown, ", "))
+	}
+	if err := checkNames(fs, fields); err != nil {
+		return err
+	}
+	defineFields(fs, fields, config)
+	return nil
+}
+
+func (d *Definer) defineFlagSet(fs *flag.FlagSet, config interface{}, strict bool) error {
+	fields, err := d.configFields(fs, config, strict)
+	if err != nil {
+		return err
+	}
+	if err := checkNames(fs, fields); err != nil {
+		return err
+	}
+	defineFields(fs, fields, config)
+	return nil
+}
+
+// configFields returns fields of config flags are to be defined for on fs
+func (d *Definer) configFields(fs *flag.FlagSet, config interface{}, strict bool) ([]field, error) {
 	if fs == nil {
-		panic(errInvalidFlagSet)
+		return nil, errInvalidFlagSet
 	}
-	st := reflect.ValueOf(config)
-	if st.Kind() != reflect.Ptr {
-		panic(errPointerWanted)
+	st, err := structValue(config)
+	if err != nil {
+		return nil, err
 	}
-	st = reflect.Indirect(st)
-	if !st.IsValid() || st.Type().Kind() != reflect.Struct {
-		panic(errInvalidArgument)
+	return d.fields(st, strict, "", "", nil)
+}
+
+// defineFields defines flags for fields of config on fs and records their
+// metadata
+func defineFields(fs *flag.FlagSet, fields []field, config interface{}) {
+	for _, f := range fields {
+		info := &flagInfo{
+			name:   f.spec.name,
+			usage:  f.spec.fullUsage(),
+			field:  f.name,
+			typ:    f.typ,
+			opts:   f.spec.opts,
+			config: config,
+		}
+		if UsageFunc != nil {
+			info.usage = UsageFunc(info.name, info.usage, info.public(f.value.String()))
+		}
+		if f.spec.opts.has("censor") {
+			f.value = &censorValue{wrapper: wrapper{f.value}, def: f.value.String()}
+		}
+		if f.spec.opts.has("once") {
+			f.value = &onceValue{wrapper: wrapper{f.value}, name: f.spec.name}
+		}
+		if f.spec.opts.has("history") {
+			f.value = &historyValue{wrapper: wrapper{f.value}}
+		}
+		if f.spec.opts.has("hidden") {
+			f.value = &hiddenValue{wrapper: wrapper{f.value}, usage: info.usage}
+		}
+		for _, name := range f.spec.names() {
+			fs.Var(f.value, name, info.usage)
+			maskDefault(fs.Lookup(name), info)
+		}
+		if DebugLogger != nil {
+			debugf("autoflags: field %s: defined flag -%s of type %s", f.name, f.spec.name, f.typ)
+		}
+		record(fs, info)
 	}
-	flagValueType := reflect.TypeOf((*flag.Value)(nil)).Elem()
+}
+
+// field is a struct field flag is to be defined for
+type field struct {
+	name  string // struct field name
+	spec  tagSpec
+	typ   reflect.Type
+	value flag.Value
+}
+
+// fields returns flag-tagged fields of st with flag values bound to them.
+// Untagged fields of struct types are walked recursively, flags of their
+// fields are grouped under the struct field name. Path is a prefix of struct
+// field names used in error messages and metadata. If errs is not nil, errors
+// are appended to it and fields causing them are skipped, otherwise the first
+// error is returned.
+func (d *Definer) fields(st reflect.Value, strict bool, path, group string, errs *errorList) ([]field, error) {
+	fail := func(err error) error {
+		if errs == nil {
+			return err
+		}
+		*errs = append(*errs, err)
+		return nil
+	}
+	var out []field
 	for i := 0; i < st.NumField(); i++ {
 		typ := st.Type().Field(i)
-		var name, usage string
-		tag := typ.Tag.Get("flag")
+		tag := typ.Tag.Get(d.tagKey())
 		if tag == "" {
+			if nested, ok := nestedStruct(st.Field(i), typ); ok {
+				if DebugLogger != nil {
+					debugf("autoflags: field %s%s: walking nested struct", path, typ.Name)
+				}
+				fields, err := d.fields(nested, strict, path+typ.Name+".", typ.Name, errs)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, fields...)
+				continue
+			}
+			if DebugLogger != nil {
+				debugf("autoflags: field %s%s: skipped, no %q tag", path, typ.Name, d.tagKey())
+			}
+			continue
+		}
+		if typ.PkgPath != "" {
+			if strict {
+				err := fmt.Errorf("autoflags: unexported field %s%s has flag tag %q", path, typ.Name, tag)
+				if err := fail(err); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if DebugLogger != nil {
+				debugf("autoflags: field %s%s: skipped, field is unexported", path, typ.Name)
+			}
 			continue
 		}
 		val := st.Field(i)
 		if !val.CanAddr() {
-			panic(errInvalidField)
-		}
-		flagData := strings.SplitN(tag, ",", 2)
-		switch len(flagData) {
-		case 1:
-			name = flagData[0]
-		case 2:
-			name, usage = flagData[0], flagData[1]
-		}
-		addr := val.Addr()
-		if addr.Type().Implements(flagValueType) {
-			fs.Var(addr.Interface().(flag.Value), name, usage)
+			return nil, errInvalidField
+		}
+		spec := parseTag(tag)
+		if spec.positional() || spec.afterDoubleDash() {
+			what := "positional arguments"
+			if spec.afterDoubleDash() {
+				what = "arguments after --"
+			}
+			if typ.Type != stringSliceType {
+				err := fmt.Errorf("autoflags: field %s%s for %s must be []string", path, typ.Name, what)
+				if err := fail(err); err != nil {
+					return nil, err
+				}
+			}
+			if DebugLogger != nil {
+				debugf("autoflags: field %s%s: skipped, field is for %s", path, typ.Name, what)
+			}
+			continue
+		}
+		if spec.name == "" {
+			if err := fail(fmt.Errorf("autoflags: field %s%s has flag tag with empty name", path, typ.Name)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		spec.name = d.flagName(spec.name)
+		if cond, ok := spec.opts["when"]; ok {
+			enabled, err := boolField(st, cond)
+			if err != nil {
+				if err := fail(fmt.Errorf("autoflags: flag %q: %w", spec.name, err)); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if !enabled {
+				if DebugLogger != nil {
+					debugf("autoflags: field %s%s: skipped, field %s is false", path, typ.Name, cond)
+				}
+				continue
+			}
+		}
+		if from, ok := spec.opts["defaultfrom"]; ok {
+			if err := defaultFrom(st, val, from); err != nil {
+				if err := fail(fmt.Errorf("autoflags: flag %q: %w", spec.name, err)); err != nil {
+					return nil, err
+				}
+				continue
+			}
+		}
+		if strict {
+			if err := spec.checkConflicts(); err != nil {
+				if err := fail(err); err != nil {
+					return nil, err
+				}
+				continue
+			}
+		}
+		if _, ok := spec.opts["group"]; !ok && group != "" {
+			if spec.opts == nil {
+				spec.opts = make(tagOptions)
+			}
+			spec.opts["group"] = group
+		}
+		v, err := d.fieldValue(val, path+typ.Name, spec)
+		if err != nil {
+			if DebugLogger != nil {
+				debugf("autoflags: field %s%s: %v", path, typ.Name, err)
+			}
+			if err := fail(err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if def, ok := spec.opts["default"]; ok && val.IsZero() {
+			if err := setDefault(v, def); err != nil {
+				if err := fail(fmt.Errorf("autoflags: flag %q: invalid default %q: %w", spec.name, def, err)); err != nil {
+					return nil, err
+				}
+				continue
+			}
+		}
+		if strict {
+			if err := checkRoundTrip(val, v); err != nil {
+				if err := fail(fmt.Errorf("autoflags: flag %q: %w", spec.name, err)); err != nil {
+					return nil, err
+				}
+				continue
+			}
+		}
+		out = append(out, field{name: path + typ.Name, spec: spec, typ: typ.Type, value: v})
+	}
+	return out, nil
+}
+
+// fieldValue returns flag.Value for the struct field val named name, taking
+// overrides of d into account
+func (d *Definer) fieldValue(val reflect.Value, name string, spec tagSpec) (flag.Value, error) {
+	if fn, ok := d.overrides[name]; ok {
+		if v := fn(val); v != nil {
+			return v, nil
+		}
+		return nil, fmt.Errorf("autoflags: flag %q: override for field %s returned nil", spec.name, name)
+	}
+	return newFieldValue(val.Addr(), spec)
+}
+
+// checkRoundTrip reports an error if non-zero default value of field val of
+// a custom flag.Value type, as told by its String method, can't be set on
+// a zero value of the same type to get the same string back
+func checkRoundTrip(val reflect.Value, v flag.Value) error {
+	if val.Kind() == reflect.Ptr || !val.Addr().Type().Implements(flagValueType) {
+		return nil
+	}
+	def := v.String()
+	z := reflect.New(val.Type()).Interface().(flag.Value)
+	if def == z.String() {
+		return nil
+	}
+	if err := z.Set(def); err != nil {
+		return fmt.Errorf("default value %q can't be set back: %w", def, err)
+	}
+	if s := z.String(); s != def {
+		return fmt.Errorf("default value %q does not round-trip, got %q after setting it", def, s)
+	}
+	return nil
+}
+
+// walkTagged calls fn for each exported flag-tagged field of st, walking
+// untagged nested structs the same way [Definer.fields] does, but without
+// creating flag values or applying defaults, so that st is not modified
+func walkTagged(st reflect.Value, tagKey string, fn func(val reflect.Value, sf reflect.StructField, spec tagSpec) error) error {
+	for i := 0; i < st.NumField(); i++ {
+		typ := st.Type().Field(i)
+		tag := typ.Tag.Get(tagKey)
+		if tag == "" {
+			if nested, ok := nestedStruct(st.Field(i), typ); ok {
+				if err := walkTagged(nested, tagKey, fn); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if typ.PkgPath != "" {
 			continue
 		}
-		switch d := val.Interface().(type) {
-		case int:
-			fs.IntVar(addr.Interface().(*int), name, d, usage)
-		case int64:
-			fs.Int64Var(addr.Interface().(*int64), name, d, usage)
-		case uint:
-			fs.UintVar(addr.Interface().(*uint), name, d, usage)
-		case uint64:
-			fs.Uint64Var(addr.Interface().(*uint64), name, d, usage)
-		case float64:
-			fs.Float64Var(addr.Interface().(*float64), name, d, usage)
-		case bool:
-			fs.BoolVar(addr.Interface().(*bool), name, d, usage)
-		case string:
-			fs.StringVar(addr.Interface().(*string), name, d, usage)
-		case time.Duration:
-			fs.DurationVar(addr.Interface().(*time.Duration), name, d, usage)
-		default:
-			panic(fmt.Sprintf("autoflags: field with flag tag value %q is of unsupported type", name))
+		if err := fn(st.Field(i), typ, parseTag(tag)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkNames reports an error if any of the flag names of fields is either
+// already defined on fs or is used by more than one field
+func checkNames(fs *flag.FlagSet, fields []field) error {
+	var defined []string
+	var errs errorList
+	owners := make(map[string]string)
+	for _, f := range fields {
+		for _, name := range f.spec.names() {
+			if fs.Lookup(name) != nil {
+				defined = append(defined, "-"+name)
+				continue
+			}
+			if owner, ok := owners[name]; ok {
+				kind := "flag"
+				switch name {
+				case f.spec.name:
+				case f.spec.opts["short"]:
+					kind = "short flag"
+				case f.spec.opts["alias"]:
+					kind = "flag alias"
+				}
+				errs = append(errs, fmt.Errorf("autoflags: %s -%s of field %s is already used by field %s",
+					kind, name, f.name, owner))
+				continue
+			}
+			owners[name] = f.name
+		}
+	}
+	if len(defined) != 0 {
+		errs = append(errs, fmt.Errorf("autoflags: flags already defined: %s", strings.Join(defined, ", ")))
+	}
+	return errs.err()
+}
+
+// uniqueNames renames flags of fields that are either already defined on fs
+// or used by preceding fields, by appending the smallest numeric suffix making
+// the name unique
+func uniqueNames(fs *flag.FlagSet, fields []field) {
+	taken := make(map[string]bool)
+	unique := func(name string) string {
+		n := name
+		for i := 2; taken[n] || fs.Lookup(n) != nil; i++ {
+			n = name + strconv.Itoa(i)
+		}
+		taken[n] = true
+		return n
+	}
+	for i := range fields {
+		spec := &fields[i].spec
+		spec.name = unique(spec.name)
+		for _, opt := range []string{"short", "alias"} {
+			if name := spec.opts[opt]; name != "" {
+				spec.opts[opt] = unique(name)
+			}
+		}
+	}
+}
+
+// setDefault sets v to def, so that the next Set call on values accumulating
+// multiple arguments replaces def instead of adding to it
+func setDefault(v flag.Value, def string) error {
+	if err := v.Set(def); err != nil {
+		return err
+	}
+	markDefault(v)
+	return nil
+}
+
+// boolField returns value of the bool field of st with the given name
+func boolField(st reflect.Value, name string) (bool, error) {
+	f, ok := st.Type().FieldByName(name)
+	if !ok || f.PkgPath != "" || f.Type.Kind() != reflect.Bool {
+		return false, fmt.Errorf("no exported bool field %q", name)
+	}
+	return st.FieldByIndex(f.Index).Bool(), nil
+}
+
+// defaultFrom sets string field v to the value of st field referenced by
+// expr, if v is empty. Expr is a field name optionally followed by "+" and a
+// suffix appended to the field value, like "DataDir+/backup".
+func defaultFrom(st, v reflect.Value, expr string) error {
+	if v.Kind() != reflect.String {
+		return errors.New("defaultfrom option requires string field")
+	}
+	name, suffix := expr, ""
+	if i := strings.IndexByte(expr, '+'); i >= 0 {
+		name, suffix = expr[:i], expr[i+1:]
+	}
+	f, ok := st.Type().FieldByName(name)
+	if !ok || f.PkgPath != "" || f.Type.Kind() != reflect.String {
+		return fmt.Errorf("no exported string field %q", name)
+	}
+	if v.String() == "" {
+		v.SetString(st.FieldByIndex(f.Index).String() + suffix)
+	}
+	return nil
+}
+
+// structValue returns struct config points to, it returns an error if config
+// is not a non-nil pointer to a struct. Pointers to pointers to a struct are
+// followed, intermediate nil pointers are set to newly allocated values.
+func structValue(config interface{}) (reflect.Value, error) {
+	st := reflect.ValueOf(config)
+	if st.Kind() == reflect.Struct {
+		return st, fmt.Errorf("%w, got struct %s passed by value, use &config to pass its address",
+			errPointerWanted, st.Type())
+	}
+	if st.Kind() != reflect.Ptr {
+		return st, errPointerWanted
+	}
+	base := st.Type()
+	for base.Kind() == reflect.Ptr {
+		base = base.Elem()
+	}
+	if st.IsNil() || base.Kind() != reflect.Struct {
+		return st, errInvalidArgument
+	}
+	// follow pointers to pointers, like **Config, allocating nil ones
+	for st = st.Elem(); st.Kind() == reflect.Ptr; st = st.Elem() {
+		if st.IsNil() {
+			st.Set(reflect.New(st.Type().Elem()))
+		}
+	}
+	return st, nil
+}
+
+// newFieldValue returns flag.Value for the field addr points to, as described
+// by spec
+func newFieldValue(addr reflect.Value, spec tagSpec) (flag.Value, error) {
+	if err := spec.check(addr.Elem().Type()); err != nil {
+		return nil, err
+	}
+	v, err := fieldValue(addr, spec)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, fmt.Errorf("autoflags: field with flag tag value %q is of unsupported type", spec.name)
+	}
+	return v, nil
+}
+
+// fieldValue returns flag.Value bound to the field addr points to, or nil if
+// field is of unsupported type.
+func fieldValue(addr reflect.Value, spec tagSpec) (flag.Value, error) {
+	if v, err := optionValue(addr, spec); err != nil || v != nil {
+		return v, err
+	}
+	check, err := elemCheck(spec.opts, elemType(addr.Elem().Type()))
+	if err != nil {
+		return nil, fmt.Errorf("autoflags: flag %q: %w", spec.name, err)
+	}
+	if v := scalarValue(addr, spec.opts); v != nil {
+		if check == nil {
+			return v, nil
+		}
+		if f, ok := addr.Interface().(*float64); ok && spec.opts.has("oneof") {
+			if err := check(strconv.FormatFloat(*f, 'g', -1, 64)); err != nil {
+				return nil, fmt.Errorf("autoflags: flag %q: default value: %w", spec.name, err)
+			}
 		}
+		if p, ok := addr.Interface().(*string); ok && spec.opts.has("pattern") && *p != "" {
+			if err := check(*p); err != nil {
+				if spec.opts.has("censor") {
+					err = censorError(err, *p)
+				}
+				return nil, fmt.Errorf("autoflags: flag %q: default value: %w", spec.name, err)
+			}
+		}
+		return &checkedValue{wrapper: wrapper{v}, check: check}, nil
 	}
+	return compositeValue(addr.Elem(), spec.opts, check), nil
 }
+
+// scalarValue returns flag.Value for the field addr points to if it's either
+// of type natively supported by the flag package or implements flag.Value
+// itself.
+func scalarValue(addr reflect.Value, opts tagOptions) flag.Value {
+	if p, ok := addr.Interface().(*CIDRSet); ok {
+		return &cidrSetValue{p: p}
+	}
+	if addr.Type().Implements(flagValueType) {
+		return addr.Interface().(flag.Value)
+	}
+	if addr.Type().Implements(textUnmarshalerType) {
+		return &textValue{addr.Elem()}
+	}
+	if typ := addr.Elem().Type(); typ.Kind() == reflect.Interface {
+		if impls := interfaceImpls(typ); len(impls) != 0 {
+			return &interfaceValue{v: addr.Elem(), impls: impls}
+		}
+		return nil
+	}
+	// values for natively supported types are created on a throwaway
+	// FlagSet, so they're indistinguishable from ones created by xxxVar
+	// methods, including the way they're printed in usage
+	const name = "x"
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	switch d := addr.Elem().Interface().(type) {
+	case int:
+		fs.IntVar(addr.Interface().(*int), name, d, "")
+	case int64:
+		fs.Int64Var(addr.Interface().(*int64), name, d, "")
+	case uint:
+		fs.UintVar(addr.Interface().(*uint), name, d, "")
+	case uint64:
+		fs.Uint64Var(addr.Interface().(*uint64), name, d, "")
+	case float64:
+		fs.Float64Var(addr.Interface().(*float64), name, d, "")
+	case bool:
+		fs.BoolVar(addr.Interface().(*bool), name, d, "")
+	case string:
+		fs.StringVar(addr.Interface().(*string), name, d, "")
+	case time.Duration:
+		fs.DurationVar(addr.Interface().(*time.Duration), name, d, "")
+	case *time.Location:
+		p := addr.Interface().(**time.Location)
+		return &locationValue{p: p, emptyUTC: opts.has("emptyutc")}
+	case json.RawMessage:
+		return &rawJSONValue{addr.Interface().(*json.RawMessage)}
+	case net.IPNet, net.HardwareAddr, time.Weekday, time.Month:
+		return &elemValue{v: addr.Elem(), parse: elemParser(addr.Elem().Type())}
+	default:
+		return nil
+	}
+	return fs.Lookup(name).Value
+}
+
+// optionValue returns flag.Value implementing behavior requested by spec
+// options for the field addr points to. It returns nil Value if field should
+// be handled as usual.
+func optionValue(addr reflect.Value, spec tagSpec) (flag.Value, error) {
+	if spec.opts.has("fromfile") {
+		p, ok := addr.Interface().(*string)
+		if !ok {
+			return nil, fmt.Errorf("autoflags: flag %q: fromfile option requires string field", spec.name)
+		}
+		return &fileValue{p}, nil
+	}
+	if spec.opts.has("prompt") {
+		p, ok := addr.Interface().(*string)
+		if !ok {
+			return nil, fmt.Errorf("autoflags: flag %q: prompt option requires string field", spec.name)
+		}
+		return &promptValue{p: p, name: spec.name}, nil
+	}
+	if bits, ok := spec.opts["bits"]; ok {
+		v, err := newBitsValue(addr.Elem(), bits)
+		if err != nil {
+			return nil, fmt.Errorf("autoflags: flag %q: %w", spec.name, err)
+		}
+		return v, nil
+	}
+	if levels, ok := spec.opts["levels"]; ok {
+		v, err := newNamedLevelValue(addr.Elem(), levels)
+		if err != nil {
+			return nil, fmt.Errorf("autoflags: flag %q: %w", spec.name, err)
+		}
+		return v, nil
+	}
+	if spec.opts.has("invert") {
+		p, ok := addr.Interface().(*bool)
+		if !ok {
+			return nil, fmt.Errorf("autoflags: flag %q: invert option requires bool field", spec.name)
+		}
+		return &invertedValue{p}, nil
+	}
+	if spec.opts.has("humanbool") {
+		if _, ok := addr.Interface().(*bool); !ok {
+			return nil, fmt.Errorf("autoflags: flag %q: humanbool option requires bool field", spec.name)
+		}
+		return &humanBoolValue{wrapper{scalarValue(addr, spec.opts)}}, nil
+	}
+	if spec.opts.has("template") {
+		p, ok := addr.Interface().(*string)
+		if !ok {
+			return nil, fmt.Errorf("autoflags: flag %q: template option requires string field", spec.name)
+		}
+		return &templateValue{p}, nil
+	}
+	if spec.opts.has("text") {
+		p, ok := addr.Interface().(*[]byte)
+		if !ok {
+			return nil, fmt.Errorf("autoflags: flag %q: text option requires []byte field", spec.name)
+		}
+		return &bytesTextValue{p}, nil
+	}
+	if unit, ok := spec.opts["defaultunit"]; ok {
+		d, err := time.ParseDuration("1" + unit)
+		if _, ok := addr.Interface().(*time.Duration); !ok || err != nil || d <= 0 {
+			return nil, fmt.Errorf("autoflags: flag %q: defaultunit option requires time.Duration field and valid unit", spec.name)
+		}
+		return &unitDurationValue{v: scalarValue(addr, spec.opts), unit: d}, nil
+	}
+	if spec.opts.has("bytesize") {
+		v, err := newSizeValue(addr.Elem())
+		if err != nil {
+			return nil, fmt.Errorf("autoflags: flag %q: %w", spec.name, err)
+		}
+		return v, nil
+	}
+	if spec.opts.has("bitrate") {
+		v, err := newRateValue(addr.Elem())
+		if err != nil {
+			return nil, fmt.Errorf("autoflags: flag %q: %w", spec.name, err)
+		}
+		return v, nil
+	}
+	return nil, nil
+}
+
+var (
+	stringSliceType     = reflect.TypeOf([]string(nil))
+	flagValueType       = reflect.TypeOf((*flag.Value)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
@@ -1,10 +1,14 @@
 package autoflags
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"log"
 	"os"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 	"unsafe"
@@ -25,6 +29,20 @@ func TestDefineErrPointerWanted(t *testing.T) {
 	Define(1)
 }
 
+func TestDefineStructByValue(t *testing.T) {
+	ResetForTesting(nil)
+	defer func() {
+		err, ok := recover().(error)
+		if !ok || !errors.Is(err, errPointerWanted) {
+			t.Fatalf("should have panicked with errPointerWanted, got %v", err)
+		}
+		if !strings.Contains(err.Error(), "autoflags.config") {
+			t.Fatalf("error should mention type name: %v", err)
+		}
+	}()
+	Define(config{})
+}
+
 func TestDefineErrInvalidArgument(t *testing.T) {
 	ResetForTesting(nil)
 	var testConfig *struct{}
@@ -85,6 +103,75 @@ func TestDefineParse(t *testing.T) {
 	}
 }
 
+func TestDefinePointerToPointer(t *testing.T) {
+	type config struct {
+		Name string `flag:"name"`
+	}
+	parse := func(conf interface{}) error {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		if err := DefineFlagSetStrict(fs, conf); err != nil {
+			return err
+		}
+		return fs.Parse([]string{"-name", "x"})
+	}
+	single := &config{}
+	if err := parse(single); err != nil || single.Name != "x" {
+		t.Fatalf("*config: %v, %+v", err, single)
+	}
+	double := &config{}
+	if err := parse(&double); err != nil || double.Name != "x" {
+		t.Fatalf("**config: %v, %+v", err, double)
+	}
+	var unset *config
+	if err := parse(&unset); err != nil || unset == nil || unset.Name != "x" {
+		t.Fatalf("**config pointing to nil: %v, %+v", err, unset)
+	}
+	if err := parse((**config)(nil)); !errors.Is(err, errInvalidArgument) {
+		t.Fatalf("nil **config: got %v, want errInvalidArgument", err)
+	}
+	var n *int
+	if err := parse(&n); !errors.Is(err, errInvalidArgument) || n != nil {
+		t.Fatalf("**int: got %v, pointer %v", err, n)
+	}
+}
+
+func TestParseWithErrorHandling(t *testing.T) {
+	conf := config{String: "foo"}
+	fs, err := ParseWithErrorHandling(&conf, []string{"-num", "7", "rest"}, flag.ContinueOnError)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Int != 7 || fs.NArg() != 1 {
+		t.Fatalf("unexpected result: %+v, args: %q", conf, fs.Args())
+	}
+	if _, err := ParseWithErrorHandling(&conf, []string{"-num", "x"}, flag.ContinueOnError); err == nil {
+		t.Fatal("parsing invalid value should fail")
+	}
+	if _, err := ParseWithErrorHandling(conf, nil, flag.ContinueOnError); !errors.Is(err, errPointerWanted) {
+		t.Fatalf("should have failed with errPointerWanted, got %v", err)
+	}
+}
+
+func TestParseAndValidate(t *testing.T) {
+	conf := struct {
+		Min int `flag:"min"`
+		Max int `flag:"max"`
+	}{Max: 10}
+	validate := func() error {
+		if conf.Min > conf.Max {
+			return errors.New("min must not exceed max")
+		}
+		return nil
+	}
+	if _, err := ParseAndValidate(&conf, []string{"-min", "5"}, validate); err != nil {
+		t.Fatal(err)
+	}
+	_, err := ParseAndValidate(&conf, []string{"-min", "20"}, validate)
+	if err == nil || err.Error() != "min must not exceed max" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
 func TestDefineFlagSetErrInvalidFlagSet(t *testing.T) {
 	defer func() {
 		if x := recover(); x != errInvalidFlagSet {
@@ -94,6 +181,251 @@ func TestDefineFlagSetErrInvalidFlagSet(t *testing.T) {
 	DefineFlagSet(nil, &struct{}{})
 }
 
+func TestDefineFlagSetStrictUnexported(t *testing.T) {
+	conf := struct {
+		Name  string `flag:"name"`
+		level int    `flag:"level"`
+	}{}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	DefineFlagSet(fs, &conf)
+	if fs.Lookup("name") == nil || fs.Lookup("level") != nil {
+		t.Fatal("DefineFlagSet should only define flag for exported field")
+	}
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	err := DefineFlagSetStrict(fs, &conf)
+	if err == nil || !strings.Contains(err.Error(), "level") {
+		t.Fatalf("DefineFlagSetStrict should fail naming unexported field, got %v", err)
+	}
+}
+
+func TestDefineFlagSetStrictRoundTrip(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	if err := DefineFlagSetStrict(fs, &struct {
+		Level levelValue `flag:"level"`
+		List  CustomFlag `flag:"list"`
+	}{Level: 2}); err != nil {
+		t.Fatal(err)
+	}
+	err := DefineFlagSetStrict(flag.NewFlagSet("test", flag.ContinueOnError), &struct {
+		List CustomFlag `flag:"list"`
+	}{List: CustomFlag{"a", "b"}})
+	want := `autoflags: flag "list": default value "[a b]" does not round-trip, got "[[a b]]" after setting it`
+	if err == nil || err.Error() != want {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	DefineFlagSet(fs, &struct {
+		List CustomFlag `flag:"list"`
+	}{List: CustomFlag{"a", "b"}})
+	if fs.Lookup("list") == nil {
+		t.Fatal("DefineFlagSet should not check defaults")
+	}
+}
+
+// levelValue is a flag.Value which default round-trips
+type levelValue int
+
+func (l *levelValue) String() string { return strconv.Itoa(int(*l)) }
+func (l *levelValue) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	*l = levelValue(n)
+	return err
+}
+
+func TestDefineWhen(t *testing.T) {
+	type conf struct {
+		EnableExperimental bool
+		Experimental       string `flag:"experimental,,when=EnableExperimental"`
+		Stable             string `flag:"stable"`
+	}
+	for _, enabled := range []bool{false, true} {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		DefineFlagSet(fs, &conf{EnableExperimental: enabled})
+		if (fs.Lookup("experimental") != nil) != enabled || fs.Lookup("stable") == nil {
+			t.Errorf("unexpected set of flags defined with EnableExperimental=%v", enabled)
+		}
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	err := DefineFlagSetStrict(fs, &struct {
+		Name string `flag:"name,,when=Missing"`
+	}{})
+	if err == nil {
+		t.Fatal("reference to missing field should fail")
+	}
+}
+
+func TestDefineDefaultFrom(t *testing.T) {
+	conf := struct {
+		DataDir   string `flag:"data-dir"`
+		BackupDir string `flag:"backup-dir,,defaultfrom=DataDir+/backup"`
+		CacheDir  string `flag:"cache-dir,,defaultfrom=DataDir"`
+		LogDir    string `flag:"log-dir,,defaultfrom=DataDir"`
+	}{DataDir: "/var/lib/app", LogDir: "/var/log/app"}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	if err := DefineFlagSetStrict(fs, &conf); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"backup-dir": "/var/lib/app/backup",
+		"cache-dir":  "/var/lib/app",
+		"log-dir":    "/var/log/app",
+	} {
+		if got := fs.Lookup(name).DefValue; got != want {
+			t.Errorf("flag -%s default is %q, want %q", name, got, want)
+		}
+	}
+	for _, c := range []interface{}{
+		&struct {
+			Dir string `flag:"dir,,defaultfrom=Missing"`
+		}{},
+		&struct {
+			Port int
+			Dir  string `flag:"dir,,defaultfrom=Port"`
+		}{},
+		&struct {
+			Dir  string
+			Port int `flag:"port,,defaultfrom=Dir"`
+		}{},
+	} {
+		if err := DefineFlagSetStrict(flag.NewFlagSet("test", flag.ContinueOnError), c); err == nil {
+			t.Errorf("DefineFlagSetStrict(%T) should fail", c)
+		}
+	}
+}
+
+func TestDebugLogger(t *testing.T) {
+	var buf strings.Builder
+	DebugLogger = log.New(&buf, "", 0)
+	defer func() { DebugLogger = nil }()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	DefineFlagSet(fs, &struct {
+		Enabled bool
+		Name    string `flag:"name"`
+		Extra   string `flag:"extra,,when=Enabled"`
+		level   int    `flag:"level"`
+	}{})
+	want := `autoflags: field Enabled: skipped, no "flag" tag
+autoflags: field Extra: skipped, field Enabled is false
+autoflags: field level: skipped, field is unexported
+autoflags: field Name: defined flag -name of type string
+`
+	if buf.String() != want {
+		t.Fatalf("got log:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestDefineTwice(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := config{}
+	if err := DefineFlagSetStrict(fs, &conf); err != nil {
+		t.Fatal(err)
+	}
+	err := DefineFlagSetStrict(fs, &conf)
+	if err == nil || err.Error() != "autoflags: flags already defined: -name, -num" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = DefineFlagSetStrict(flag.NewFlagSet("test", flag.ContinueOnError), &struct {
+		A string `flag:"name"`
+		B string `flag:"name"`
+	}{})
+	if err == nil || err.Error() != "autoflags: flag -name of field B is already used by field A" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestDefineFlagSetUnique(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	var c1, c2 config
+	if err := DefineFlagSetUnique(fs, &c1); err != nil {
+		t.Fatal(err)
+	}
+	if err := DefineFlagSetUnique(fs, &c2); err != nil {
+		t.Fatal(err)
+	}
+	if err := DefineFlagSetUnique(fs, &struct {
+		A string `flag:"name"`
+		B string `flag:"name"`
+	}{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"name", "num", "name2", "num2", "name3", "name4"} {
+		if fs.Lookup(name) == nil {
+			t.Errorf("flag -%s is not defined", name)
+		}
+	}
+	if err := fs.Parse([]string{"-name2", "Jane"}); err != nil {
+		t.Fatal(err)
+	}
+	if c1.String != "" || c2.String != "Jane" {
+		t.Fatalf("unexpected names: %q, %q", c1.String, c2.String)
+	}
+}
+
+func TestDefineShortConflict(t *testing.T) {
+	err := DefineFlagSetStrict(flag.NewFlagSet("test", flag.ContinueOnError), &struct {
+		Verbose bool `flag:"verbose,,short=v"`
+		Version bool `flag:"version,,short=v"`
+	}{})
+	if err == nil || err.Error() != "autoflags: short flag -v of field Version is already used by field Verbose" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = DefineFlagSetStrict(flag.NewFlagSet("test", flag.ContinueOnError), &struct {
+		V       bool `flag:"v"`
+		Verbose bool `flag:"verbose,,short=v"`
+	}{})
+	if err == nil || err.Error() != "autoflags: short flag -v of field Verbose is already used by field V" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = DefineFlagSetStrict(flag.NewFlagSet("test", flag.ContinueOnError), &struct {
+		Verbose bool `flag:"verbose,,short=v"`
+		Version bool `flag:"version,,short=V"`
+	}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDefineFlagSetWithOverrides(t *testing.T) {
+	conf := struct {
+		Name string `flag:"name"`
+		DB   struct {
+			Password string `flag:"db-password"`
+		}
+	}{}
+	upper := func(v reflect.Value) flag.Value {
+		return upperValue{v.Addr().Interface().(*string)}
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	err := DefineFlagSetWithOverrides(fs, &conf, map[string]func(reflect.Value) flag.Value{
+		"DB.Password": upper,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Parse([]string{"-name", "john", "-db-password", "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Name != "john" || conf.DB.Password != "SECRET" {
+		t.Fatalf("unexpected result: %+v", conf)
+	}
+	err = DefineFlagSetWithOverrides(flag.NewFlagSet("test", flag.ContinueOnError), &conf,
+		map[string]func(reflect.Value) flag.Value{"Password": upper})
+	if err == nil || err.Error() != "autoflags: overrides for unknown fields: Password" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// upperValue is a flag.Value storing its argument upper-cased
+type upperValue struct{ p *string }
+
+func (u upperValue) String() string {
+	if u.p == nil {
+		return ""
+	}
+	return *u.p
+}
+func (u upperValue) Set(s string) error { *u.p = strings.ToUpper(s); return nil }
+
 func TestUnsupportedFlagType(t *testing.T) {
 	ResetForTesting(nil)
 	defer func() {
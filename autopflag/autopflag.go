@@ -0,0 +1,71 @@
+// Package autopflag declares flags from structs tagged for package
+// [github.com/artyom/autoflags] on [pflag.FlagSet], so that projects using
+// github.com/spf13/pflag for POSIX-style flags can keep their tagged config
+// structs:
+//
+//	var config = struct {
+//		Name    string `flag:"name,user name"`
+//		Verbose bool   `flag:"verbose,verbose output,short=v"`
+//	}{}
+//
+//	if err := autopflag.DefinePflag(pflag.CommandLine, &config); err != nil {
+//		log.Fatal(err)
+//	}
+//	pflag.Parse()
+package autopflag // import "github.com/artyom/autoflags/autopflag"
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/artyom/autoflags"
+	"github.com/spf13/pflag"
+)
+
+// DefinePflag takes pointer to a struct and declares flags for its flag-tagged
+// fields on a given pflag FlagSet. It understands the same tags and field types
+// as [autoflags.DefineFlagSet] does; names given with short option are used as
+// pflag shorthands, so they must be a single ASCII character, names given with
+// alias option are defined as hidden flags sharing the value. If any flag can't
+// be defined, an error is returned and fs is left unchanged.
+func DefinePflag(fs *pflag.FlagSet, config interface{}) error {
+	if fs == nil {
+		return errors.New("autopflag: non-nil FlagSet expected")
+	}
+	gofs := flag.NewFlagSet("", flag.ContinueOnError)
+	defer autoflags.Forget(gofs)
+	if err := autoflags.DefineFlagSetStrict(gofs, config); err != nil {
+		return err
+	}
+	var flags []*pflag.Flag
+	for _, info := range autoflags.Flags(gofs) {
+		f := pflag.PFlagFromGoFlag(gofs.Lookup(info.Name))
+		if info.Short != "" {
+			if len(info.Short) != 1 || info.Short[0] >= utf8.RuneSelf {
+				return fmt.Errorf("autopflag: flag %q: shorthand %q is not a single ASCII character", f.Name, info.Short)
+			}
+			f.Shorthand = info.Short
+		}
+		f.Hidden = info.Hidden
+		flags = append(flags, f)
+		if info.Alias != "" {
+			alias := pflag.PFlagFromGoFlag(gofs.Lookup(info.Alias))
+			alias.Hidden = true
+			flags = append(flags, alias)
+		}
+	}
+	for _, f := range flags {
+		if fs.Lookup(f.Name) != nil {
+			return fmt.Errorf("autopflag: flag %q is already defined", f.Name)
+		}
+		if f.Shorthand != "" && fs.ShorthandLookup(f.Shorthand) != nil {
+			return fmt.Errorf("autopflag: flag %q: shorthand %q is already used", f.Name, f.Shorthand)
+		}
+	}
+	for _, f := range flags {
+		fs.AddFlag(f)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package autopflag
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+func TestDefinePflag(t *testing.T) {
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	conf := struct {
+		Name    string        `flag:"name,user name"`
+		Verbose bool          `flag:"verbose,verbose output,short=v"`
+		Timeout time.Duration `flag:"timeout"`
+		Tags    []string      `flag:"tag"`
+	}{Name: "John Doe"}
+	if err := DefinePflag(fs, &conf); err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"--name=Jane Roe", "-v", "--timeout", "1m", "--tag", "a,b"}
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Name != "Jane Roe" || !conf.Verbose || conf.Timeout != time.Minute ||
+		!reflect.DeepEqual(conf.Tags, []string{"a", "b"}) {
+		t.Fatalf("unexpected result: %+v", conf)
+	}
+	if err := DefinePflag(fs, &conf); err == nil {
+		t.Fatal("defining the same flags twice should fail")
+	}
+}
+
+func TestDefinePflagAlias(t *testing.T) {
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	conf := struct {
+		Output string `flag:"output,,alias=out"`
+	}{}
+	if err := DefinePflag(fs, &conf); err != nil {
+		t.Fatal(err)
+	}
+	if f := fs.Lookup("out"); f == nil || !f.Hidden {
+		t.Fatal("alias should be defined as hidden flag")
+	}
+	if err := fs.Parse([]string{"--out", "a.txt"}); err != nil || conf.Output != "a.txt" {
+		t.Fatalf("unexpected result: %q, %v", conf.Output, err)
+	}
+}
+
+func TestDefinePflagErrors(t *testing.T) {
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	bad := struct {
+		Verbose bool `flag:"verbose,,short=vv"`
+	}{}
+	if err := DefinePflag(fs, &bad); err == nil {
+		t.Fatal("multi-character shorthand should fail")
+	}
+	fs.Bool("port", false, "")
+	conf := struct {
+		Host string `flag:"host"`
+		Port int    `flag:"port"`
+	}{}
+	if err := DefinePflag(fs, &conf); err == nil {
+		t.Fatal("defining already defined flag should fail")
+	}
+	if fs.Lookup("host") != nil {
+		t.Fatal("no flags should be defined on failure")
+	}
+}
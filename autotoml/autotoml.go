@@ -0,0 +1,211 @@
+// Package autotoml dumps config structs tagged for package
+// [github.com/artyom/autoflags] as TOML, so that tools whose ecosystem uses
+// TOML can show effective configuration after flags are parsed:
+//
+//	autoflags.Parse(&config)
+//	if *dumpConfig {
+//		if err := autotoml.SaveTOML(&config, os.Stdout); err != nil {
+//			log.Fatal(err)
+//		}
+//	}
+//
+// It is a separate package so that users of autoflags not needing TOML don't
+// depend on a TOML library; encoding is done by
+// [github.com/pelletier/go-toml/v2].
+package autotoml // import "github.com/artyom/autoflags/autotoml"
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/artyom/autoflags"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// SaveTOML writes current values of config, which must be a struct or
+// a pointer to it, to w as TOML document. Keys are taken from `toml` tags if
+// present, otherwise field names are used; fields tagged with "-" and
+// unexported fields are skipped, as well as nil pointers and interfaces.
+// Nested structs are written as tables, maps with string keys as tables too,
+// slices and arrays as arrays.
+//
+// TOML has no native types for many values flags are used for, so
+// time.Duration, types implementing [encoding.TextMarshaler] and non-scalar
+// types implementing [fmt.Stringer], like net.IPNet or *time.Location, are
+// written as strings; time.Time is written as offset date-time. Values that
+// can't be represented in TOML, like uint64 numbers above math.MaxInt64, are
+// reported as errors.
+//
+// Values of fields with censor option in `flag` tag are masked by
+// [github.com/artyom/autoflags.Censored], the same way SaveJSON does.
+func SaveTOML(config interface{}, w io.Writer) error {
+	v := reflect.ValueOf(autoflags.Censored(config))
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return errors.New("autotoml: pointer to struct or struct expected")
+	}
+	doc, err := convert(v, nil)
+	if err != nil {
+		return err
+	}
+	if err := toml.NewEncoder(w).SetIndentTables(false).Encode(doc); err != nil {
+		return fmt.Errorf("autotoml: %w", err)
+	}
+	return nil
+}
+
+// convert returns v as a value encoded by the TOML library the way SaveTOML
+// describes: structs are converted to structs of the same field names and
+// `toml` tags, with fields holding converted values; path holds names of the
+// struct fields leading to v, for error messages
+func convert(v reflect.Value, path []string) (interface{}, error) {
+	v, ok := deref(v)
+	if !ok {
+		return nil, errors.New("autotoml: nil values can't be written")
+	}
+	if v.Type() == timeType {
+		return v.Interface(), nil
+	}
+	if s, ok, err := textForm(v); ok || err != nil {
+		if err != nil {
+			return nil, fmt.Errorf("autotoml: field %s: %w", strings.Join(path, "."), err)
+		}
+		return s, nil
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		return convertStruct(v, path)
+	case reflect.Slice, reflect.Array:
+		elems := make([]interface{}, v.Len())
+		for i := range elems {
+			elem, err := convert(v.Index(i), path)
+			if err != nil {
+				return nil, err
+			}
+			elems[i] = elem
+		}
+		return elems, nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("autotoml: field %s: maps with %s keys are not supported",
+				strings.Join(path, "."), v.Type().Key())
+		}
+		m := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			elem, err := convert(iter.Value(), path)
+			if err != nil {
+				return nil, err
+			}
+			m[iter.Key().String()] = elem
+		}
+		return m, nil
+	}
+	return v.Interface(), nil
+}
+
+// convertStruct converts struct v into a value of a struct type having the
+// same exported fields, except for skipped ones, each holding converted value
+func convertStruct(v reflect.Value, path []string) (interface{}, error) {
+	var fields []reflect.StructField
+	var values []interface{}
+	for i := 0; i < v.NumField(); i++ {
+		sf := v.Type().Field(i)
+		if name := strings.SplitN(sf.Tag.Get("toml"), ",", 2)[0]; sf.PkgPath != "" || name == "-" {
+			continue
+		}
+		fpath := append(path[:len(path):len(path)], sf.Name)
+		fv, ok := deref(v.Field(i))
+		if !ok {
+			continue
+		}
+		val, err := convert(fv, fpath)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, reflect.StructField{
+			Name: sf.Name,
+			Type: reflect.TypeOf((*interface{})(nil)).Elem(),
+			Tag:  reflect.StructTag("toml:" + strconv.Quote(sf.Tag.Get("toml"))),
+		})
+		values = append(values, val)
+	}
+	out := reflect.New(reflect.StructOf(fields)).Elem()
+	for i, val := range values {
+		out.Field(i).Set(reflect.ValueOf(&val).Elem())
+	}
+	return out.Interface(), nil
+}
+
+// deref returns v with pointers and interfaces dereferenced, it reports false
+// if v is nil. Pointers implementing encoding.TextMarshaler or fmt.Stringer
+// are kept, as their methods may have pointer receivers.
+func deref(v reflect.Value) (reflect.Value, bool) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return v, false
+		}
+		if v.Kind() == reflect.Ptr && hasTextForm(v) {
+			return v, true
+		}
+		v = v.Elem()
+	}
+	return v, true
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// textForm returns text representation of v if it is of a type written as
+// TOML string
+func textForm(v reflect.Value) (string, bool, error) {
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String(), true, nil
+	}
+	if !v.CanAddr() && v.Kind() != reflect.Ptr {
+		cp := reflect.New(v.Type()).Elem()
+		cp.Set(v)
+		v = cp
+	}
+	candidates := []reflect.Value{v}
+	if v.CanAddr() {
+		candidates = append(candidates, v.Addr())
+	}
+	for _, c := range candidates {
+		if m, ok := c.Interface().(encoding.TextMarshaler); ok {
+			b, err := m.MarshalText()
+			return string(b), true, err
+		}
+	}
+	switch v.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "", false, nil
+	}
+	for _, c := range candidates {
+		if s, ok := c.Interface().(fmt.Stringer); ok {
+			return s.String(), true, nil
+		}
+	}
+	return "", false, nil
+}
+
+func hasTextForm(v reflect.Value) bool {
+	if v.Type() == timeType {
+		return false
+	}
+	_, ok, _ := textForm(v)
+	return ok
+}
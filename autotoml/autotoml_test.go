@@ -0,0 +1,94 @@
+package autotoml
+
+import (
+	"math"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSaveTOML(t *testing.T) {
+	_, network, _ := net.ParseCIDR("10.0.0.0/8")
+	conf := struct {
+		Name    string        `flag:"name" toml:"name"`
+		Workers int           `flag:"workers"`
+		Ratio   float64       `flag:"ratio"`
+		Verbose bool          `flag:"v"`
+		Timeout time.Duration `flag:"timeout"`
+		Tags    []string      `flag:"tag" toml:"tags"`
+		Labels  map[string]string
+		Addr    net.IP
+		Network net.IPNet
+		Secret  string `toml:"-"`
+		Skipped *int
+		Server  struct {
+			Host string `toml:"host"`
+			TLS  struct {
+				Cert string
+			}
+		}
+		private int
+	}{
+		Name:    "say \"hi\"\n",
+		Workers: 4,
+		Ratio:   2,
+		Timeout: 90 * time.Second,
+		Tags:    []string{"a", "b"},
+		Labels:  map[string]string{"env": "prod", "app.kubernetes.io/name": "web"},
+		Addr:    net.IPv4(192, 0, 2, 1),
+		Network: *network,
+		Secret:  "x",
+	}
+	conf.Server.Host = "localhost"
+	conf.Server.TLS.Cert = "cert.pem"
+	var b strings.Builder
+	if err := SaveTOML(&conf, &b); err != nil {
+		t.Fatal(err)
+	}
+	want := `name = "say \"hi\"\n"
+Workers = 4
+Ratio = 2.0
+Verbose = false
+Timeout = '1m30s'
+tags = ['a', 'b']
+Addr = '192.0.2.1'
+Network = '10.0.0.0/8'
+
+[Labels]
+'app.kubernetes.io/name' = 'web'
+env = 'prod'
+
+[Server]
+host = 'localhost'
+
+[Server.TLS]
+Cert = 'cert.pem'
+`
+	if got := b.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if err := SaveTOML(conf.Workers, &b); err == nil {
+		t.Fatal("non-struct config should fail")
+	}
+	if err := SaveTOML(struct{ Big uint64 }{math.MaxInt64 + 1}, &b); err == nil {
+		t.Fatal("uint64 above math.MaxInt64 should fail")
+	}
+}
+
+func TestSaveTOMLCensor(t *testing.T) {
+	conf := struct {
+		Token string `flag:"token,,censor"`
+		PIN   int    `flag:"pin,,censor"`
+		Empty string `flag:"empty,,censor"`
+		User  string `flag:"user"`
+	}{Token: "secret", PIN: 1234, User: "admin"}
+	var b strings.Builder
+	if err := SaveTOML(&conf, &b); err != nil {
+		t.Fatal(err)
+	}
+	want := "Token = '********'\nPIN = 0\nEmpty = ''\nUser = 'admin'\n"
+	if got := b.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
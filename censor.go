@@ -0,0 +1,191 @@
+package autoflags
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// censoredText replaces values of flags with censor option in output
+const censoredText = "********"
+
+// censored reports whether values of the flag must be masked in output, which
+// is the case for flags with censor option
+func (info *flagInfo) censored() bool {
+	return info != nil && info.opts.has("censor")
+}
+
+// censorFields replaces non-zero values of fields of struct st tagged with
+// censor option, walking nested structs the same way [DefineFlagSet] does:
+// strings, or pointers to them, are set to censoredText, fields of other
+// types to their zero values. St must be settable, like a struct returned by
+// [Clone].
+func censorFields(st reflect.Value) {
+	walkTagged(st, defaultDefiner.tagKey(), func(val reflect.Value, _ reflect.StructField, spec tagSpec) error {
+		if val.IsZero() || !spec.opts.has("censor") {
+			return nil
+		}
+		if val.Kind() == reflect.Ptr {
+			val = val.Elem()
+		}
+		if val.Kind() == reflect.String {
+			val.SetString(censoredText)
+			return nil
+		}
+		val.Set(reflect.Zero(val.Type()))
+		return nil
+	})
+}
+
+// Censored returns a copy of config made by [Clone], with values of fields
+// tagged with censor option masked the way [SaveJSON] writes them: non-empty
+// strings are replaced with "********", values of other types with zero
+// values. Config may be a struct or a pointer to it. Censored is meant for
+// packages writing configs in other formats.
+func Censored(config interface{}) interface{} {
+	cp := deepCopy(config)
+	v := reflect.ValueOf(cp)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return cp
+	}
+	if !v.CanAddr() {
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		censorFields(p.Elem())
+		return p.Interface()
+	}
+	censorFields(v)
+	return cp
+}
+
+// censorValue wraps flag.Value of a flag with censor option, masking its
+// argument in errors of Set. It keeps the actual default value, as DefValue
+// of such flags is masked.
+type censorValue struct {
+	wrapper
+	def      string // actual default value
+	rejected string // argument of the last failed Set call
+}
+
+func (c *censorValue) Set(s string) error {
+	if err := c.v.Set(s); err != nil {
+		c.rejected = s
+		return censorError(err, s)
+	}
+	return nil
+}
+
+func (c *censorValue) markDefault() { c.def = c.v.String() }
+
+// censorValueOf returns censorValue among wrappers of v, or nil if there is
+// none
+func censorValueOf(v flag.Value) *censorValue {
+	for v != nil {
+		if c, ok := v.(*censorValue); ok {
+			return c
+		}
+		w, ok := v.(interface{ wrapped() flag.Value })
+		if !ok {
+			return nil
+		}
+		v = w.wrapped()
+	}
+	return nil
+}
+
+// defaultValue returns default value of f as text; unlike f.DefValue, it is
+// not masked for flags with censor option
+func defaultValue(f *flag.Flag) string {
+	if c := censorValueOf(f.Value); c != nil {
+		return c.def
+	}
+	return f.DefValue
+}
+
+// maskDefault masks f.DefValue of flags with censor option, unless it is the
+// zero value which reveals nothing
+func maskDefault(f *flag.Flag, info *flagInfo) {
+	if info.censored() && !isZeroValue(f) {
+		f.DefValue = censoredText
+	}
+}
+
+// mask returns s with all occurrences of arg, either as is or quoted,
+// replaced with censoredText
+func mask(s, arg string) string {
+	if arg == "" {
+		return s
+	}
+	s = strings.ReplaceAll(s, strconv.Quote(arg), strconv.Quote(censoredText))
+	return strings.ReplaceAll(s, arg, censoredText)
+}
+
+// censorError returns err with arg masked in its text; err is returned as is
+// if its text does not contain arg
+func censorError(err error, arg string) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	if masked := mask(msg, arg); masked != msg {
+		return errors.New(masked)
+	}
+	return err
+}
+
+// censoredArg returns arg to be used in error messages about the flag with
+// the given name defined on fs, which is censoredText for flags with censor
+// option
+func censoredArg(fs *flag.FlagSet, name, arg string) string {
+	if flagInfoMap(fs)[name].censored() {
+		return censoredText
+	}
+	return arg
+}
+
+// parseCensored parses args with fs, masking arguments rejected by flags with
+// censor option in messages the flag package writes to fs output and in the
+// returned error
+func parseCensored(fs *flag.FlagSet, args []string) error {
+	var values []*censorValue
+	fs.VisitAll(func(f *flag.Flag) {
+		if c := censorValueOf(f.Value); c != nil {
+			c.rejected = ""
+			values = append(values, c)
+		}
+	})
+	if len(values) == 0 {
+		return fs.Parse(args)
+	}
+	w := &censorWriter{w: fs.Output(), values: values}
+	fs.SetOutput(w)
+	defer fs.SetOutput(w.w)
+	err := fs.Parse(args)
+	for _, c := range values {
+		err = censorError(err, c.rejected)
+	}
+	return err
+}
+
+// censorWriter masks arguments rejected by values written to w
+type censorWriter struct {
+	w      io.Writer
+	values []*censorValue
+}
+
+func (cw *censorWriter) Write(p []byte) (int, error) {
+	s := string(p)
+	for _, c := range cw.values {
+		s = mask(s, c.rejected)
+	}
+	if _, err := io.WriteString(cw.w, s); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,182 @@
+package autoflags
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCensor(t *testing.T) {
+	type config struct {
+		Token string `flag:"token,API token,censor,history"`
+		PIN   int    `flag:"pin,,censor"`
+		User  string `flag:"user"`
+		DB    struct {
+			Password string `flag:"db-password,,censor" json:"password"`
+		}
+	}
+	conf := config{Token: "default-token", PIN: 1234, User: "admin"}
+	conf.DB.Password = "hunter2"
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	DefineFlagSet(fs, &conf)
+	if err := fs.Parse([]string{"-token", "first-token", "-token", "second-token"}); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Token != "second-token" {
+		t.Fatalf("field should hold real value, got %q", conf.Token)
+	}
+	secrets := []string{"default-token", "first-token", "second-token", "1234", "hunter2"}
+	checkMasked := func(what, out string) {
+		t.Helper()
+		for _, s := range secrets {
+			if strings.Contains(out, s) {
+				t.Errorf("%s has %q:\n%s", what, s, out)
+			}
+		}
+		if !strings.Contains(out, censoredText) {
+			t.Errorf("%s has no %q:\n%s", what, censoredText, out)
+		}
+	}
+
+	var buf bytes.Buffer
+	fs.SetOutput(&buf)
+	PrintDefaults(fs)
+	checkMasked("PrintDefaults output", buf.String())
+	if !strings.Contains(buf.String(), `(default "admin")`) {
+		t.Errorf("PrintDefaults output should have default of -user:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	PrintByRequirement(fs, &buf)
+	checkMasked("PrintByRequirement output", buf.String())
+
+	buf.Reset()
+	if err := SaveJSON(&conf, &buf); err != nil {
+		t.Fatal(err)
+	}
+	checkMasked("SaveJSON output", buf.String())
+	if !strings.Contains(buf.String(), `"admin"`) || !strings.Contains(buf.String(), `"PIN": 0`) {
+		t.Errorf("unexpected SaveJSON output:\n%s", buf.String())
+	}
+	if conf.Token != "second-token" || conf.DB.Password != "hunter2" {
+		t.Fatal("SaveJSON modified config")
+	}
+
+	var defaults []string
+	for _, info := range Flags(fs) {
+		defaults = append(defaults, info.Default)
+	}
+	checkMasked("Flags defaults", strings.Join(defaults, " "))
+
+	buf.Reset()
+	if err := WriteMarkdown(&config{Token: "default-token", PIN: 1234}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	checkMasked("WriteMarkdown output", buf.String())
+
+	history := History(fs, "token")
+	if len(history) != 2 {
+		t.Fatalf("unexpected history length: %q", history)
+	}
+	checkMasked("History", strings.Join(history, " "))
+}
+
+func TestCensorErrors(t *testing.T) {
+	type config struct {
+		Token string `flag:"token,,censor,pattern=^tk-[a-z]+$,env=TEST_CENSOR_TOKEN"`
+		PIN   int    `flag:"pin,,censor"`
+	}
+	const secret = "Hunter2"
+	checkMasked := func(what string, err error) {
+		t.Helper()
+		if err == nil {
+			t.Fatalf("%s: error expected", what)
+		}
+		if strings.Contains(err.Error(), secret) {
+			t.Errorf("%s error has secret: %v", what, err)
+		}
+		if !strings.Contains(err.Error(), censoredText) {
+			t.Errorf("%s error has no %q: %v", what, censoredText, err)
+		}
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	checkMasked("default", DefineFlagSetStrict(fs, &config{Token: secret}))
+
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	var buf bytes.Buffer
+	fs.SetOutput(&buf)
+	if err := DefineFlagSetStrict(fs, &config{}); err != nil {
+		t.Fatal(err)
+	}
+	err := ParseWithFieldErrors(fs, []string{"-pin", secret})
+	checkMasked("ParseWithFieldErrors", err)
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.Value != censoredText {
+		t.Errorf("unexpected FieldError: %#v", fe)
+	}
+	if strings.Contains(buf.String(), secret) {
+		t.Errorf("ParseWithFieldErrors output has secret:\n%s", buf.String())
+	}
+
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if err := DefineFlagSetStrict(fs, &config{}); err != nil {
+		t.Fatal(err)
+	}
+	checkMasked("ParseGNU", ParseGNU(fs, []string{"--token=" + secret}))
+
+	checkMasked("Apply", Apply(&config{}, map[string]string{"pin": secret}))
+	checkMasked("LoadKV", LoadKV(&config{}, strings.NewReader("token="+secret+"\n")))
+
+	os.Setenv("TEST_CENSOR_TOKEN", secret)
+	defer os.Unsetenv("TEST_CENSOR_TOKEN")
+	checkMasked("ApplyEnv", ApplyEnv(&config{}))
+
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	if err := DefineFlagSetStrict(fs, &config{}); err != nil {
+		t.Fatal(err)
+	}
+	checkMasked("ApplyDefaults", ApplyDefaults(fs, strings.NewReader(`{"pin": "`+secret+`"}`)))
+}
+
+func TestCensorDefValue(t *testing.T) {
+	conf := struct {
+		Token string `flag:"token,,censor,nodefaultvalue"`
+		User  string `flag:"user"`
+	}{Token: "Hunter2", User: "admin"}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	var buf bytes.Buffer
+	fs.SetOutput(&buf)
+	DefineFlagSet(fs, &conf)
+	if got := fs.Lookup("token").DefValue; got != censoredText {
+		t.Fatalf("DefValue of -token is %q, want %q", got, censoredText)
+	}
+	fs.PrintDefaults()
+	if strings.Contains(buf.String(), "Hunter2") || !strings.Contains(buf.String(), "admin") {
+		t.Errorf("unexpected flag.FlagSet.PrintDefaults output:\n%s", buf.String())
+	}
+	if err := fs.Parse([]string{"-token", "Hunter2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(fs, &conf); err == nil {
+		t.Fatal("Verify should report value equal to the default")
+	}
+	if err := fs.Parse([]string{"-token", "other"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(fs, &conf); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ApplyDefaults(fs, strings.NewReader(`{"token": "new-default"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if got := fs.Lookup("token").DefValue; got != censoredText {
+		t.Fatalf("DefValue of -token after ApplyDefaults is %q", got)
+	}
+}
@@ -0,0 +1,245 @@
+package autoflags
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Check takes pointer to a struct and reports whether flags can be defined for
+// it without actually defining them: it performs the same checks as
+// [DefineFlagSetStrict], but instead of stopping at the first problem, it
+// reports all of them at once, like fields of unsupported types, tags with
+// empty names or names used by multiple fields. Check is meant to be used in
+// tests to catch incompatible changes of config structs:
+//
+//	func TestConfig(t *testing.T) {
+//		if err := autoflags.Check(&config{}); err != nil {
+//			t.Fatal(err)
+//		}
+//	}
+//
+// Check does not modify config: flag values are bound to its copy, so that
+// defaults set by options like default or defaultfrom are not applied to
+// config itself.
+func Check(config interface{}) error {
+	st, err := structValue(deepCopy(config))
+	if err != nil {
+		return err
+	}
+	var errs errorList
+	fields, err := defaultDefiner.fields(st, true, "", "", &errs)
+	if err != nil {
+		return err
+	}
+	if err := checkNames(flag.NewFlagSet("", flag.ContinueOnError), fields); err != nil {
+		errs = append(errs, err)
+	}
+	return errs.err()
+}
+
+// errorList is an error combining multiple errors, one per line
+type errorList []error
+
+func (l errorList) Error() string {
+	msgs := make([]string, len(l))
+	for i, err := range l {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// err returns l as an error, or nil if l is empty
+func (l errorList) err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
+
+// CheckExclusive is supposed to be called after fs is parsed, it reports an
+// error if more than one flag from the same exclusive group was set. Flags are
+// put into groups with exclusive option:
+//
+//	JSON bool `flag:"json,,exclusive=format"`
+//	YAML bool `flag:"yaml,,exclusive=format"`
+func CheckExclusive(fs *flag.FlagSet) error {
+	seen := setFlags(fs)
+	var groups []string
+	set := make(map[string][]string)
+	for _, info := range flagInfos(fs) {
+		group, ok := info.opts["exclusive"]
+		if !ok {
+			continue
+		}
+		if _, ok := set[group]; !ok {
+			groups = append(groups, group)
+			set[group] = nil
+		}
+		if info.isSet(seen) {
+			set[group] = append(set[group], "-"+info.name)
+		}
+	}
+	for _, group := range groups {
+		if names := set[group]; len(names) > 1 {
+			return fmt.Errorf("autoflags: flags %s are mutually exclusive",
+				strings.Join(names, ", "))
+		}
+	}
+	return nil
+}
+
+// CheckRequired is supposed to be called after fs is parsed, it reports an
+// error listing flags having required option that were not set.
+func CheckRequired(fs *flag.FlagSet) error {
+	missing := MissingRequired(fs)
+	if len(missing) == 0 {
+		return nil
+	}
+	for i := range missing {
+		missing[i] = "-" + missing[i]
+	}
+	return fmt.Errorf("autoflags: required flags not set: %s", strings.Join(missing, ", "))
+}
+
+// CheckMinLen is supposed to be called after fs is parsed, it reports an error
+// if any slice flag having minlen option has fewer elements than required:
+//
+//	Peers []string `flag:"peers,,minlen=2,maxlen=16"`
+//
+// Unlike maxlen, which is enforced each time flag is set, minimal length can
+// only be checked once all arguments are processed. Default values count, so
+// the check passes for a flag not set if its default is long enough.
+func CheckMinLen(fs *flag.FlagSet) error {
+	var errs errorList
+	verifySlices(fs, nil, &errs, "minlen")
+	return errs.err()
+}
+
+// Verify is supposed to be called after fs is parsed, it checks constraints
+// of flags defined on fs for config which can only be verified once all
+// arguments are processed: minimal length of slices set with minlen option,
+// see [CheckMinLen], limit of the total of durations set with maxtotal option,
+// and values of flags with nodefaultvalue option, which must differ from
+// their defaults:
+//
+//	Backoff []time.Duration `flag:"backoff,,maxtotal=5m"`
+//	APIKey  string          `flag:"api-key,,nodefaultvalue"`
+//
+// The latter catches placeholder defaults never overridden; unlike required
+// option, setting such flag to its default value does not satisfy the check.
+// Config must be the same pointer flags were defined for. All violations are
+// reported at once.
+func Verify(fs *flag.FlagSet, config interface{}) error {
+	if config == nil {
+		return errInvalidArgument
+	}
+	var errs errorList
+	for _, info := range flagInfos(fs) {
+		if info.config != config || !info.opts.has("nodefaultvalue") {
+			continue
+		}
+		if f := fs.Lookup(info.name); f != nil && f.Value.String() == defaultValue(f) {
+			errs = append(errs, fmt.Errorf("autoflags: flag -%s must be set to a value other than its default", info.name))
+		}
+	}
+	verifySlices(fs, config, &errs, "minlen", "maxtotal")
+	return errs.err()
+}
+
+// verifySlices checks slice flags defined on fs for config, or for any config
+// if it is nil, against constraints of the given options, appending
+// violations to errs
+func verifySlices(fs *flag.FlagSet, config interface{}, errs *errorList, opts ...string) {
+	for _, info := range flagInfos(fs) {
+		if config != nil && info.config != config {
+			continue
+		}
+		f := fs.Lookup(info.name)
+		if f == nil {
+			continue
+		}
+		sv, ok := unwrapValue(f.Value).(*sliceValue)
+		if !ok {
+			continue
+		}
+		for _, opt := range opts {
+			v, ok := info.opts[opt]
+			if !ok {
+				continue
+			}
+			// option values are validated by tagSpec.check
+			switch opt {
+			case "minlen":
+				if n, _ := strconv.Atoi(v); sv.s.Len() < n {
+					*errs = append(*errs, fmt.Errorf("autoflags: flag -%s has %d elements, at least %s required",
+						info.name, sv.s.Len(), v))
+				}
+			case "maxtotal":
+				limit, _ := time.ParseDuration(v)
+				var total time.Duration
+				for i := 0; i < sv.s.Len(); i++ {
+					total += time.Duration(sv.s.Index(i).Int())
+				}
+				if total > limit {
+					*errs = append(*errs, fmt.Errorf("autoflags: flag -%s: total of durations %v exceeds %v",
+						info.name, total, limit))
+				}
+			}
+		}
+	}
+}
+
+// DetectShadows returns sorted names of flags defined on both parent and
+// child, like global flags redefined by a subcommand FlagSet. Such child flags
+// shadow those of the parent, which is usually a mistake when parent flags
+// are expected to also be accepted after the subcommand name.
+func DetectShadows(parent, child *flag.FlagSet) []string {
+	var names []string
+	child.VisitAll(func(f *flag.Flag) {
+		if parent.Lookup(f.Name) != nil {
+			names = append(names, f.Name)
+		}
+	})
+	// VisitAll visits flags in lexicographical order
+	return names
+}
+
+// MissingRequired is supposed to be called after fs is parsed, it returns
+// names of flags having required option that were not set, in order of their
+// definition. Names are returned without leading dash.
+func MissingRequired(fs *flag.FlagSet) []string {
+	seen := setFlags(fs)
+	var missing []string
+	for _, info := range flagInfos(fs) {
+		if info.opts.has("required") && !info.isSet(seen) {
+			missing = append(missing, info.name)
+		}
+	}
+	return missing
+}
+
+// WarnDeprecated is supposed to be called after fs is parsed, it prints to fs
+// output a warning for each deprecated flag that was set. Flags are marked as
+// deprecated with deprecated option, which applies to flag alias if it has
+// one:
+//
+//	Name string `flag:"new-name,usage,alias=old-name,deprecated=use -new-name"`
+func WarnDeprecated(fs *flag.FlagSet) {
+	seen := setFlags(fs)
+	for _, info := range flagInfos(fs) {
+		msg, ok := info.opts["deprecated"]
+		if !ok {
+			continue
+		}
+		name := info.name
+		if alias := info.opts["alias"]; alias != "" {
+			name = alias
+		}
+		if seen[name] {
+			fmt.Fprintf(fs.Output(), "flag -%s is deprecated: %s\n", name, msg)
+		}
+	}
+}
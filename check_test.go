@@ -0,0 +1,253 @@
+package autoflags
+
+import (
+	"bytes"
+	"flag"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCheck(t *testing.T) {
+	if err := Check(&configBig{}); err != nil {
+		t.Fatal(err)
+	}
+	err := Check(&struct {
+		A     string  `flag:"name"`
+		B     string  `flag:"name"`
+		C     int32   `flag:"small"`
+		D     string  `flag:",usage"`
+		e     string  `flag:"e"`
+		Valid float64 `flag:"valid"`
+	}{})
+	want := `autoflags: field with flag tag value "small" is of unsupported type
+autoflags: field D has flag tag with empty name
+autoflags: unexported field e has flag tag "e"
+autoflags: flag -name of field B is already used by field A`
+	if err == nil || err.Error() != want {
+		t.Fatalf("got error:\n%v\nwant:\n%s", err, want)
+	}
+}
+
+func TestCheckUnmodified(t *testing.T) {
+	type conf struct {
+		Count int    `flag:"count,,default=5"`
+		Dir   string `flag:"dir"`
+		Back  string `flag:"back,,defaultfrom=Dir+/b"`
+	}
+	c := conf{Dir: "/d"}
+	if err := Check(&c); err != nil {
+		t.Fatal(err)
+	}
+	if want := (conf{Dir: "/d"}); c != want {
+		t.Fatalf("config modified: got %+v, want %+v", c, want)
+	}
+	if err := Check(conf{}); err == nil {
+		t.Fatal("struct passed by value should fail")
+	}
+}
+
+func TestCheckExclusive(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		ok   bool
+	}{
+		{nil, true},
+		{[]string{"-json"}, true},
+		{[]string{"-json", "-verbose"}, true},
+		{[]string{"-json", "-yaml"}, false},
+	} {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		conf := struct {
+			JSON    bool `flag:"json,,exclusive=format"`
+			YAML    bool `flag:"yaml,,exclusive=format"`
+			Text    bool `flag:"text,,exclusive=format"`
+			Verbose bool `flag:"verbose"`
+		}{}
+		DefineFlagSet(fs, &conf)
+		if err := fs.Parse(tc.args); err != nil {
+			t.Fatal(err)
+		}
+		err := CheckExclusive(fs)
+		if (err == nil) != tc.ok {
+			t.Errorf("args %q: unexpected CheckExclusive result: %v", tc.args, err)
+		}
+		if err != nil && err.Error() != "autoflags: flags -json, -yaml are mutually exclusive" {
+			t.Errorf("unexpected error text: %v", err)
+		}
+		Forget(fs)
+		if err := CheckExclusive(fs); err != nil || len(flagInfos(fs)) != 0 {
+			t.Errorf("args %q: metadata should be forgotten: %v", tc.args, err)
+		}
+	}
+}
+
+func TestCheckRequired(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Name string `flag:"name,,required"`
+		Addr string `flag:"addr,,required"`
+		Age  uint   `flag:"age"`
+	}{}
+	DefineFlagSet(fs, &conf)
+	if err := fs.Parse([]string{"-addr", ":80"}); err != nil {
+		t.Fatal(err)
+	}
+	err := CheckRequired(fs)
+	if err == nil || err.Error() != "autoflags: required flags not set: -name" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := fs.Parse([]string{"-name", ""}); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckRequired(fs); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckMinLen(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Peers   []string `flag:"peer,,minlen=2"`
+		Servers []string `flag:"server,,minlen=1"`
+	}{Servers: []string{"localhost"}}
+	DefineFlagSet(fs, &conf)
+	if err := fs.Parse([]string{"-peer", "a"}); err != nil {
+		t.Fatal(err)
+	}
+	err := CheckMinLen(fs)
+	if want := "autoflags: flag -peer has 1 elements, at least 2 required"; err == nil || err.Error() != want {
+		t.Fatalf("got error %v, want %q", err, want)
+	}
+	if err := fs.Set("peer", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckMinLen(fs); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Backoff []time.Duration `flag:"backoff,,maxtotal=5m"`
+		Peers   []string        `flag:"peer,,minlen=1"`
+	}{}
+	other := struct {
+		Hosts []string `flag:"host,,minlen=1"`
+	}{}
+	DefineFlagSet(fs, &conf)
+	DefineFlagSet(fs, &other)
+	if err := fs.Parse([]string{"-backoff", "1m,2m", "-backoff", "3m", "-peer", "a"}); err != nil {
+		t.Fatal(err)
+	}
+	err := Verify(fs, &conf)
+	if want := "autoflags: flag -backoff: total of durations 6m0s exceeds 5m0s"; err == nil || err.Error() != want {
+		t.Fatalf("got error %v, want %q", err, want)
+	}
+	conf.Backoff = conf.Backoff[:2]
+	if err := Verify(fs, &conf); err != nil {
+		t.Fatal(err)
+	}
+	if err := DefineFlagSetStrict(flag.NewFlagSet("test", flag.ContinueOnError), &struct {
+		Backoff []int `flag:"backoff,,maxtotal=5m"`
+	}{}); err == nil {
+		t.Fatal("maxtotal option on []int should fail")
+	}
+}
+
+func TestVerifyNoDefaultValue(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		APIKey string `flag:"api-key,,nodefaultvalue"`
+		Region string `flag:"region,,nodefaultvalue"`
+	}{APIKey: "CHANGEME"}
+	DefineFlagSet(fs, &conf)
+	if err := fs.Parse([]string{"-api-key", "CHANGEME", "-region", "eu"}); err != nil {
+		t.Fatal(err)
+	}
+	err := Verify(fs, &conf)
+	if want := "autoflags: flag -api-key must be set to a value other than its default"; err == nil || err.Error() != want {
+		t.Fatalf("got error %v, want %q", err, want)
+	}
+	if err := fs.Set("api-key", "s3cret"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(fs, &conf); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDetectShadows(t *testing.T) {
+	parent := flag.NewFlagSet("prog", flag.ContinueOnError)
+	DefineFlagSet(parent, &struct {
+		Verbose bool   `flag:"v,,alias=verbose"`
+		Config  string `flag:"config"`
+	}{})
+	child := flag.NewFlagSet("serve", flag.ContinueOnError)
+	DefineFlagSet(child, &struct {
+		Addr    string `flag:"addr"`
+		Verbose bool   `flag:"verbose"`
+		Config  string `flag:"config"`
+	}{})
+	if got, want := DetectShadows(parent, child), []string{"config", "verbose"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if got := DetectShadows(parent, flag.NewFlagSet("", flag.ContinueOnError)); len(got) != 0 {
+		t.Fatalf("got %q for empty FlagSet", got)
+	}
+}
+
+func TestMissingRequired(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Name string `flag:"name,,required"`
+		Addr string `flag:"addr,,required,short=a"`
+		Port int    `flag:"port,,required"`
+	}{}
+	DefineFlagSet(fs, &conf)
+	if err := fs.Parse([]string{"-a", ":80"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := MissingRequired(fs), []string{"name", "port"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if got := MissingRequired(flag.NewFlagSet("test", flag.ContinueOnError)); len(got) != 0 {
+		t.Fatalf("got %q for FlagSet without required flags", got)
+	}
+}
+
+func TestWarnDeprecated(t *testing.T) {
+	newFlagSet := func() (*flag.FlagSet, *bytes.Buffer) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		buf := new(bytes.Buffer)
+		fs.SetOutput(buf)
+		conf := struct {
+			Name string `flag:"new-name,user name,alias=old-name,deprecated=use -new-name"`
+			Old  bool   `flag:"old,,deprecated=no longer used"`
+		}{}
+		DefineFlagSet(fs, &conf)
+		return fs, buf
+	}
+	fs, buf := newFlagSet()
+	if err := fs.Parse([]string{"-new-name", "x"}); err != nil {
+		t.Fatal(err)
+	}
+	WarnDeprecated(fs)
+	if buf.Len() != 0 {
+		t.Fatalf("unexpected warning: %q", buf)
+	}
+	PrintDefaults(fs)
+	if want := "  -new-name string\n    \tuser name\n  -old\n    \t\n"; buf.String() != want {
+		t.Fatalf("got usage:\n%q\nwant:\n%q", buf, want)
+	}
+	fs, buf = newFlagSet()
+	if err := fs.Parse([]string{"-old-name", "x", "-old"}); err != nil {
+		t.Fatal(err)
+	}
+	WarnDeprecated(fs)
+	want := "flag -old-name is deprecated: use -new-name\nflag -old is deprecated: no longer used\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("got:\n%q\nwant:\n%q", got, want)
+	}
+}
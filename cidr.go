@@ -0,0 +1,87 @@
+package autoflags
+
+import (
+	"net"
+	"strings"
+)
+
+// CIDRSet is a list of networks which can be used as a field type for
+// allow or deny lists:
+//
+//	Allow autoflags.CIDRSet `flag:"allow,networks to allow"`
+//
+// It takes comma-separated networks in CIDR notation, like
+// 10.0.0.0/8,192.168.0.0/16; repeated flags add networks to the set, though
+// for fields defined by this package the first one replaces the default, like
+// for other slices. Empty argument adds no networks, so it can be used to
+// clear the default. Overlapping or duplicate networks are kept, they don't
+// affect Contains.
+type CIDRSet []*net.IPNet
+
+// Contains reports whether ip belongs to any network of the set.
+func (s CIDRSet) Contains(ip net.IP) bool {
+	for _, n := range s {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s CIDRSet) String() string {
+	nets := make([]string, len(s))
+	for i, n := range s {
+		nets[i] = n.String()
+	}
+	return strings.Join(nets, ",")
+}
+
+// Set implements [flag.Value] interface.
+func (s *CIDRSet) Set(arg string) error {
+	out := *s
+	if arg == "" {
+		return nil
+	}
+	for _, elem := range strings.Split(arg, ",") {
+		_, n, err := net.ParseCIDR(strings.TrimSpace(elem))
+		if err != nil {
+			return err
+		}
+		out = append(out, n)
+	}
+	*s = out
+	return nil
+}
+
+// Get implements [flag.Getter] interface.
+func (s *CIDRSet) Get() interface{} { return *s }
+
+// cidrSetValue is a flag.Value for CIDRSet fields, the first Set call on it
+// replaces the default instead of adding to it
+type cidrSetValue struct {
+	p   *CIDRSet
+	set bool
+}
+
+func (v *cidrSetValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	return v.p.String()
+}
+
+func (v *cidrSetValue) Set(arg string) error {
+	out := *v.p
+	if !v.set {
+		out = nil
+	}
+	if err := out.Set(arg); err != nil {
+		return err
+	}
+	*v.p, v.set = out, true
+	return nil
+}
+
+func (v *cidrSetValue) Get() interface{} { return *v.p }
+
+func (v *cidrSetValue) markDefault() { v.set = false }
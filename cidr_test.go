@@ -0,0 +1,59 @@
+package autoflags
+
+import (
+	"flag"
+	"net"
+	"testing"
+)
+
+func TestCIDRSet(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Allow CIDRSet `flag:"allow"`
+	}{}
+	DefineFlagSet(fs, &conf)
+	args := []string{"-allow", "10.0.0.0/8,192.168.0.0/16", "-allow", "10.1.0.0/16"}
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	if got := fs.Lookup("allow").Value.String(); got != "10.0.0.0/8,192.168.0.0/16,10.1.0.0/16" {
+		t.Fatalf("unexpected value: %q", got)
+	}
+	for ip, want := range map[string]bool{
+		"10.2.3.4":    true,
+		"192.168.1.1": true,
+		"172.16.0.1":  false,
+	} {
+		if got := conf.Allow.Contains(net.ParseIP(ip)); got != want {
+			t.Errorf("Contains(%s) = %v, want %v", ip, got, want)
+		}
+	}
+	if err := fs.Set("allow", "10.0.0.0/8,bad"); err == nil {
+		t.Fatal("setting malformed network should fail")
+	}
+	if len(conf.Allow) != 3 {
+		t.Fatalf("failed Set modified the set: %v", conf.Allow)
+	}
+}
+
+func TestCIDRSetDefault(t *testing.T) {
+	_, def, _ := net.ParseCIDR("10.0.0.0/8")
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Allow CIDRSet `flag:"allow"`
+		Deny  CIDRSet `flag:"deny"`
+	}{Allow: CIDRSet{def}, Deny: CIDRSet{def}}
+	DefineFlagSet(fs, &conf)
+	if got := fs.Lookup("allow").DefValue; got != "10.0.0.0/8" {
+		t.Fatalf("unexpected default: %q", got)
+	}
+	if err := fs.Parse([]string{"-allow", "192.168.0.0/16", "-deny", ""}); err != nil {
+		t.Fatal(err)
+	}
+	if got := conf.Allow.String(); got != "192.168.0.0/16" {
+		t.Fatalf("first flag should replace default, got %q", got)
+	}
+	if len(conf.Deny) != 0 {
+		t.Fatalf("empty argument should clear default, got %v", conf.Deny)
+	}
+}
@@ -0,0 +1,117 @@
+package autoflags
+
+import "reflect"
+
+// Clone returns a deep copy of config, which is usually a pointer to a struct,
+// so that defaults can be snapshotted before [Define] and flag parsing change
+// them, and later restored or compared:
+//
+//	defaults := autoflags.Clone(&config).(*Config)
+//
+// Clone returns value of the same type as config: for a pointer, a pointer to
+// a newly allocated copy. Nested structs, slices, arrays, maps, pointers and
+// values in interfaces are copied recursively, pointers to the same value
+// remain pointing to the same copy. Unexported struct fields can't be set
+// individually through reflection, so they're copied shallowly along with the
+// struct holding them; this keeps types like time.Time intact. Pointers to
+// structs having only unexported fields, like *time.Location, as well as
+// channels and functions, are shared.
+func Clone(config interface{}) interface{} { return deepCopy(config) }
+
+// deepCopy returns a deep copy of config, see [Clone]. It is used to define
+// flags on throwaway FlagSets without modifying config, as definition applies
+// defaults given by tag options to fields.
+func deepCopy(config interface{}) interface{} {
+	if config == nil {
+		return nil
+	}
+	c := &cloner{seen: make(map[ptrKey]reflect.Value)}
+	return c.clone(reflect.ValueOf(config)).Interface()
+}
+
+// ptrKey identifies pointers already copied by cloner
+type ptrKey struct {
+	typ reflect.Type
+	ptr uintptr
+}
+
+type cloner struct {
+	seen map[ptrKey]reflect.Value
+}
+
+// opaque reports whether typ is a struct without exported fields, like
+// time.Location; pointers to such structs are not copied, as their copies may
+// not work the same way
+func opaque(typ reflect.Type) bool {
+	if typ.Kind() != reflect.Struct || typ.NumField() == 0 {
+		return false
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		if typ.Field(i).PkgPath == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// clone returns a deep copy of v
+func (c *cloner) clone(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		if opaque(v.Type().Elem()) {
+			return v
+		}
+		key := ptrKey{v.Type(), v.Pointer()}
+		if p, ok := c.seen[key]; ok {
+			return p
+		}
+		p := reflect.New(v.Type().Elem())
+		c.seen[key] = p
+		p.Elem().Set(c.clone(v.Elem()))
+		return p
+	case reflect.Interface:
+		out := reflect.New(v.Type()).Elem()
+		if !v.IsNil() {
+			out.Set(c.clone(v.Elem()))
+		}
+		return out
+	case reflect.Struct:
+		out := reflect.New(v.Type()).Elem()
+		out.Set(v) // copies unexported fields shallowly
+		for i := 0; i < v.NumField(); i++ {
+			if f := out.Field(i); f.CanSet() {
+				f.Set(c.clone(v.Field(i)))
+			}
+		}
+		return out
+	case reflect.Slice:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(c.clone(v.Index(i)))
+		}
+		return out
+	case reflect.Array:
+		out := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(c.clone(v.Index(i)))
+		}
+		return out
+	case reflect.Map:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		out := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out.SetMapIndex(c.clone(iter.Key()), c.clone(iter.Value()))
+		}
+		return out
+	}
+	return v
+}
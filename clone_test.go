@@ -0,0 +1,69 @@
+package autoflags
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestClone(t *testing.T) {
+	type inner struct {
+		Hosts []string
+	}
+	type config struct {
+		Name    string
+		Tags    []string
+		Labels  map[string][]int
+		Inner   inner
+		Ptr     *inner
+		Same    *inner
+		Any     interface{}
+		Started time.Time
+		Loc     *time.Location
+		hidden  []int
+	}
+	shared := &inner{Hosts: []string{"b"}}
+	orig := &config{
+		Name:    "x",
+		Tags:    []string{"a"},
+		Labels:  map[string][]int{"k": {1}},
+		Inner:   inner{Hosts: []string{"a"}},
+		Ptr:     shared,
+		Same:    shared,
+		Any:     []string{"c"},
+		Started: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Loc:     time.Local,
+		hidden:  []int{1},
+	}
+	cp, ok := Clone(orig).(*config)
+	if !ok {
+		t.Fatalf("unexpected type %T", Clone(orig))
+	}
+	if cp == orig || !reflect.DeepEqual(cp, orig) {
+		t.Fatalf("copy differs: %+v", cp)
+	}
+	cp.Tags[0] = "changed"
+	cp.Labels["k"][0] = 2
+	cp.Inner.Hosts[0] = "changed"
+	cp.Ptr.Hosts[0] = "changed"
+	cp.Any.([]string)[0] = "changed"
+	if orig.Tags[0] != "a" || orig.Labels["k"][0] != 1 || orig.Inner.Hosts[0] != "a" ||
+		shared.Hosts[0] != "b" || orig.Any.([]string)[0] != "c" {
+		t.Fatalf("original modified through copy: %+v", orig)
+	}
+	if cp.Ptr != cp.Same {
+		t.Fatal("pointers to the same value should point to the same copy")
+	}
+	if cp.Loc != time.Local {
+		t.Fatal("*time.Location should be shared")
+	}
+	if !cp.Started.Equal(orig.Started) {
+		t.Fatalf("time not copied: %v", cp.Started)
+	}
+	if s, ok := Clone(config{Name: "y"}).(config); !ok || s.Name != "y" {
+		t.Fatalf("unexpected copy of struct value: %+v", s)
+	}
+	if Clone(nil) != nil {
+		t.Fatal("Clone(nil) should return nil")
+	}
+}
@@ -0,0 +1,53 @@
+package autoflags
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// CompletionZsh returns zsh completion script for program prog with flags
+// defined on fs. Flags are described with their usage strings, flags having
+// fromfile option complete file names. Hidden flags and aliases are not
+// listed. Script defines _prog function, it can be either put into a file
+// named _prog in one of $fpath directories, or sourced directly:
+//
+//	source <(prog -zsh-completion)
+func CompletionZsh(fs *flag.FlagSet, prog string) string {
+	fn := "_" + strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, prog)
+	infos := flagInfoMap(fs)
+	var b strings.Builder
+	fmt.Fprintf(&b, "#compdef %s\n\n%s() {\n\t_arguments", prog, fn)
+	fs.VisitAll(func(f *flag.Flag) {
+		info := infos[f.Name]
+		if info.unlisted(f.Name) {
+			return
+		}
+		name, usage := unquoteUsage(f, info)
+		if i := strings.IndexByte(usage, '\n'); i >= 0 {
+			usage = usage[:i]
+		}
+		spec := "-" + f.Name + "[" + zshEscape(usage) + "]"
+		if !isBoolFlag(f) {
+			action := ""
+			if info != nil && info.opts.has("fromfile") {
+				action = "_files"
+			}
+			spec += ":" + zshEscape(name) + ":" + action
+		}
+		fmt.Fprintf(&b, " \\\n\t\t'%s'", strings.ReplaceAll(spec, "'", `'\''`))
+	})
+	fmt.Fprintf(&b, "\n}\n\nif [ \"$funcstack[1]\" = %q ]; then\n\t%s \"$@\"\nelse\n\tcompdef %s %s\nfi\n",
+		fn, fn, fn, prog)
+	return b.String()
+}
+
+// zshEscape escapes characters having special meaning in _arguments specs
+func zshEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`, ":", `\:`).Replace(s)
+}
@@ -0,0 +1,34 @@
+package autoflags
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestCompletionZsh(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	DefineFlagSet(fs, &struct {
+		Addr   string `flag:"addr,address [host:port]"`
+		Token  string `flag:"token,file with user's token,fromfile"`
+		V      bool   `flag:"v,verbose"`
+		Secret string `flag:"secret,,hidden"`
+	}{})
+	want := `#compdef my-app
+
+_my_app() {
+	_arguments \
+		'-addr[address \[host\:port\]]:string:' \
+		'-token[file with user'\''s token]:value:_files' \
+		'-v[verbose]'
+}
+
+if [ "$funcstack[1]" = "_my_app" ]; then
+	_my_app "$@"
+else
+	compdef _my_app my-app
+fi
+`
+	if got := CompletionZsh(fs, "my-app"); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,57 @@
+package autoflags
+
+import (
+	"flag"
+	"reflect"
+)
+
+// Definer declares flags from config structs applying the same settings to
+// all of them. Its zero value behaves the same way as package-level functions.
+type Definer struct {
+	// Prefix is prepended to names of all defined flags
+	Prefix string
+	// TagKey is a struct tag key to take flag definitions from, "flag" is
+	// used if empty
+	TagKey string
+	// NameFunc, if not nil, is called with the flag name found in tag, its
+	// result (with Prefix prepended) is used as an actual flag name
+	NameFunc func(name string) string
+
+	// overrides maps struct field names to functions creating their flag
+	// values, see DefineFlagSetWithOverrides
+	overrides map[string]func(reflect.Value) flag.Value
+}
+
+// WithPrefix returns Definer which prepends prefix to names of all flags it
+// declares. It is useful when independent components, like plugins, register
+// their flags on the same FlagSet:
+//
+//	autoflags.WithPrefix("cache.").Define(&cacheConfig)
+func WithPrefix(prefix string) *Definer { return &Definer{Prefix: prefix} }
+
+// Define works like package-level [Define] function, applying d settings.
+func (d *Definer) Define(config interface{}) { d.DefineFlagSet(flag.CommandLine, config) }
+
+// DefineFlagSet works like package-level [DefineFlagSet] function, applying d
+// settings.
+func (d *Definer) DefineFlagSet(fs *flag.FlagSet, config interface{}) {
+	if err := d.defineFlagSet(fs, config, false); err != nil {
+		panic(err)
+	}
+}
+
+var defaultDefiner = &Definer{}
+
+func (d *Definer) tagKey() string {
+	if d.TagKey == "" {
+		return "flag"
+	}
+	return d.TagKey
+}
+
+func (d *Definer) flagName(name string) string {
+	if d.NameFunc != nil {
+		name = d.NameFunc(name)
+	}
+	return d.Prefix + name
+}
@@ -0,0 +1,28 @@
+package autoflags
+
+import (
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestDefiner(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Name string `flag:"name"`
+		Addr string `flag:"addr" opt:"Listen-Addr"`
+	}{}
+	WithPrefix("plugin.").DefineFlagSet(fs, &conf)
+	if fs.Lookup("plugin.name") == nil || fs.Lookup("plugin.addr") == nil {
+		t.Fatal("flags should be defined with prefix")
+	}
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	d := &Definer{Prefix: "x-", TagKey: "opt", NameFunc: strings.ToLower}
+	d.DefineFlagSet(fs, &conf)
+	if err := fs.Parse([]string{"-x-listen-addr", ":80"}); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Addr != ":80" || fs.Lookup("x-name") != nil {
+		t.Fatalf("unexpected result: %+v", conf)
+	}
+}
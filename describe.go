@@ -0,0 +1,76 @@
+package autoflags
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Describe takes pointer to a struct and returns descriptions of flags that
+// would be defined for it by [DefineFlagSet], without defining them. Config
+// is not modified: defaults given by tag options are only applied to its copy.
+func Describe(config interface{}) ([]FlagInfo, error) {
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	defer Forget(fs)
+	if err := defaultDefiner.defineFlagSet(fs, deepCopy(config), false); err != nil {
+		return nil, err
+	}
+	return Flags(fs), nil
+}
+
+// WriteMarkdown writes to w a Markdown table describing flags that would be
+// defined for config, with Name, Type, Default, Required and Description
+// columns. Hidden flags are omitted. If some flags belong to groups, each
+// group gets its own table under a heading with the group name.
+func WriteMarkdown(config interface{}, w io.Writer) error {
+	infos, err := Describe(config)
+	if err != nil {
+		return err
+	}
+	var groups []string
+	grouped := make(map[string][]FlagInfo)
+	for _, info := range infos {
+		if info.Hidden {
+			continue
+		}
+		if _, ok := grouped[info.Group]; !ok && info.Group != "" {
+			groups = append(groups, info.Group)
+		}
+		grouped[info.Group] = append(grouped[info.Group], info)
+	}
+	bw := bufio.NewWriter(w)
+	var wrote bool
+	for _, g := range append([]string{""}, groups...) {
+		if len(grouped[g]) == 0 {
+			continue
+		}
+		if wrote {
+			bw.WriteString("\n")
+		}
+		wrote = true
+		if g != "" {
+			fmt.Fprintf(bw, "### %s\n\n", g)
+		}
+		bw.WriteString("| Name | Type | Default | Required | Description |\n")
+		bw.WriteString("|------|------|---------|----------|-------------|\n")
+		for _, info := range grouped[g] {
+			var def, req string
+			if info.Default != "" {
+				def = "`" + info.Default + "`"
+			}
+			if info.Required {
+				req = "yes"
+			}
+			fmt.Fprintf(bw, "| `-%s` | `%s` | %s | %s | %s |\n", info.Name, info.Type,
+				mdEscape(def), req, mdEscape(info.Usage))
+		}
+	}
+	return bw.Flush()
+}
+
+// mdEscape escapes text to be put into a Markdown table cell
+func mdEscape(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
@@ -0,0 +1,49 @@
+package autoflags
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestWriteMarkdown(t *testing.T) {
+	conf := struct {
+		Name    string        `flag:"name,user name,required"`
+		Timeout time.Duration `flag:"timeout,a|b"`
+		TLS     struct {
+			Cert string `flag:"cert,certificate file"`
+		}
+	}{Timeout: time.Second}
+	var buf bytes.Buffer
+	if err := WriteMarkdown(&conf, &buf); err != nil {
+		t.Fatal(err)
+	}
+	want := "| Name | Type | Default | Required | Description |\n" +
+		"|------|------|---------|----------|-------------|\n" +
+		"| `-name` | `string` |  | yes | user name |\n" +
+		"| `-timeout` | `time.Duration` | `1s` |  | a\\|b |\n" +
+		"\n### TLS\n\n" +
+		"| Name | Type | Default | Required | Description |\n" +
+		"|------|------|---------|----------|-------------|\n" +
+		"| `-cert` | `string` |  |  | certificate file |\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestDescribeUnmodified(t *testing.T) {
+	conf := struct {
+		Dir   string `flag:"dir"`
+		Cache string `flag:"cache,,defaultfrom=Dir+/cache"`
+	}{Dir: "/var"}
+	infos, err := Describe(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 2 || infos[1].Default != "/var/cache" {
+		t.Fatalf("unexpected descriptions: %+v", infos)
+	}
+	if conf.Cache != "" {
+		t.Fatalf("Describe modified config: %+v", conf)
+	}
+}
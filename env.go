@@ -0,0 +1,194 @@
+package autoflags
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EnvPrefixer is implemented by configs which declare prefix of environment
+// variables for [ApplyEnv].
+type EnvPrefixer interface {
+	EnvPrefix() string
+}
+
+// ApplyEnv takes pointer to a struct and sets its flag-tagged fields from
+// environment variables. Variable name is taken from the env option of the
+// field tag:
+//
+//	Addr string `flag:"listen-addr,,env=LISTEN_ADDR"`
+//
+// If config implements [EnvPrefixer], variable names are also derived for
+// fields without env option: flag name is converted with [EnvName] and
+// prefixed by EnvPrefix, so that with "MYAPP_" prefix -listen-addr flag is
+// set from MYAPP_LISTEN_ADDR variable. Explicit env option takes precedence
+// over the derived name and is used as is, without prefix.
+//
+// Like [Apply], ApplyEnv is meant to be called before [Define], so that values
+// from the environment become defaults that command line flags can override,
+// and it applies defaults given by tag options to config the same way.
+func ApplyEnv(config interface{}) error {
+	p, ok := config.(EnvPrefixer)
+	if !ok {
+		return applyEnv(config, nil)
+	}
+	prefix := p.EnvPrefix()
+	return applyEnv(config, func(info *flagInfo) string { return prefix + EnvName(info.name) })
+}
+
+// ApplyEnvWithPrefix works like [ApplyEnv] for config implementing
+// [EnvPrefixer] returning prefix, except that variable names are derived from
+// flag names by mapName, if it is not nil, instead of [EnvName]. Prefix is
+// prepended to mapName result:
+//
+//	// -server-port flag is set from APP_SERVER_PORT
+//	autoflags.ApplyEnvWithPrefix(&config, "APP_", nil)
+//
+// Explicit env tag options take precedence and are used as is.
+func ApplyEnvWithPrefix(config interface{}, prefix string, mapName func(flagName string) string) error {
+	if mapName == nil {
+		mapName = EnvName
+	}
+	return applyEnv(config, func(info *flagInfo) string { return prefix + mapName(info.name) })
+}
+
+// applyEnv sets fields of config from environment variables, names of
+// variables for fields without env option are derived from flag metadata by
+// envName, if it is not nil
+func applyEnv(config interface{}, envName func(*flagInfo) string) error {
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	defer Forget(fs)
+	if err := defaultDefiner.defineFlagSet(fs, config, false); err != nil {
+		return err
+	}
+	for _, info := range flagInfos(fs) {
+		name, ok := info.opts["env"]
+		if !ok {
+			if envName == nil {
+				continue
+			}
+			name = envName(info)
+		}
+		s, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := setLenient(fs, info.name, s); err != nil {
+			return fmt.Errorf("autoflags: invalid value %q of environment variable %s for flag %s: %w",
+				censoredArg(fs, info.name, s), name, info.name, err)
+		}
+	}
+	return nil
+}
+
+// EnvName is the default rule of deriving environment variable name from flag
+// or field name used by [ApplyEnv] and [DefineFromEnv]: name is upper-cased
+// and characters other than ASCII letters and digits are replaced with
+// underscores, so that "server.listen-addr" becomes "SERVER_LISTEN_ADDR".
+// CamelCase words are separated by underscores too, keeping acronyms intact:
+// "MaxRetries" becomes "MAX_RETRIES", "HTTPSProxyURL" becomes
+// "HTTPS_PROXY_URL".
+func EnvName(name string) string {
+	isUpper := func(b byte) bool { return b >= 'A' && b <= 'Z' }
+	isLower := func(b byte) bool { return b >= 'a' && b <= 'z' }
+	isDigit := func(b byte) bool { return b >= '0' && b <= '9' }
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !isUpper(c) && !isLower(c) && !isDigit(c) {
+			b.WriteByte('_')
+			continue
+		}
+		if isUpper(c) && i > 0 {
+			prev := name[i-1]
+			// word starts after lower case letter or digit, or at the
+			// last capital of an acronym followed by a lower case one,
+			// like P in HTTPPort
+			if isLower(prev) || isDigit(prev) ||
+				(isUpper(prev) && i+1 < len(name) && isLower(name[i+1])) {
+				b.WriteByte('_')
+			}
+		}
+		if isLower(c) {
+			c -= 'a' - 'A'
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// DefineFromEnv works like [DefineFlagSet], but before defining flags it sets
+// fields of config from environment variables, so that their values become
+// flag defaults. Variable names are derived from struct field names with
+// [EnvName] and prefixed with prefix: with "APP_" prefix, field MaxRetries is
+// set from APP_MAX_RETRIES variable, and field Port of nested struct HTTP
+// from APP_HTTP_PORT. Explicit env tag options take precedence and are used as
+// is. DefineFromEnv returns an error instead of panicking if config is
+// invalid.
+func DefineFromEnv(fs *flag.FlagSet, config interface{}, prefix string) error {
+	if fs == nil {
+		return errInvalidFlagSet
+	}
+	err := applyEnv(config, func(info *flagInfo) string {
+		return prefix + EnvName(info.field)
+	})
+	if err != nil {
+		return err
+	}
+	return defaultDefiner.defineFlagSet(fs, config, false)
+}
+
+// Resolve is supposed to be called after fs is parsed, it sets flags defined
+// on fs for config which were not set on the command line from fallback
+// sources, in the following order of precedence:
+//
+//  1. explicit command line flag;
+//  2. file named by fromfile option value, if it exists;
+//  3. environment variable named by env option;
+//  4. default value.
+//
+// For example, token below is read from /run/secrets/token if -token flag is
+// not given and the file exists, otherwise from TOKEN variable, if it is set:
+//
+//	Token string `flag:"token,,fromfile=/run/secrets/token,env=TOKEN"`
+//
+// Since fromfile option makes flag argument a file name, the environment
+// variable of such flag also names a file. Fallback values go through flag
+// Set method, so they are validated the same way command line arguments are,
+// and flags set by Resolve are reported as set. Config must be the same
+// pointer flags were defined for.
+func Resolve(fs *flag.FlagSet, config interface{}) error {
+	if config == nil {
+		return errInvalidArgument
+	}
+	seen := setFlags(fs)
+	for _, info := range flagInfos(fs) {
+		if info.config != config || info.isSet(seen) {
+			continue
+		}
+		if path := info.opts["fromfile"]; path != "" {
+			_, err := os.Stat(path)
+			if err == nil {
+				if err := fs.Set(info.name, path); err != nil {
+					return fmt.Errorf("autoflags: flag %s: %w", info.name, err)
+				}
+				continue
+			}
+			if !os.IsNotExist(err) {
+				return fmt.Errorf("autoflags: flag %s: %w", info.name, err)
+			}
+		}
+		name, ok := info.opts["env"]
+		if !ok {
+			continue
+		}
+		if s, ok := os.LookupEnv(name); ok {
+			if err := setLenient(fs, info.name, s); err != nil {
+				return fmt.Errorf("autoflags: invalid value %q of environment variable %s for flag %s: %w",
+					censoredArg(fs, info.name, s), name, info.name, err)
+			}
+		}
+	}
+	return nil
+}
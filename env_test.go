@@ -0,0 +1,211 @@
+package autoflags
+
+import (
+	"flag"
+	"os"
+	"testing"
+)
+
+type envConfig struct {
+	Addr    string `flag:"listen-addr"`
+	Workers int    `flag:"workers"`
+	Token   string `flag:"token,,env=API_TOKEN"`
+	Debug   bool   `flag:"debug"`
+}
+
+func (envConfig) EnvPrefix() string { return "MYAPP_" }
+
+func setenv(t *testing.T, env map[string]string) {
+	for k, v := range env {
+		if err := os.Setenv(k, v); err != nil {
+			t.Fatal(err)
+		}
+		k := k
+		t.Cleanup(func() { os.Unsetenv(k) })
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	setenv(t, map[string]string{
+		"MYAPP_LISTEN_ADDR": ":8080",
+		"MYAPP_WORKERS":     "4",
+		"MYAPP_TOKEN":       "ignored",
+		"API_TOKEN":         "s3cr3t",
+	})
+	conf := envConfig{Debug: true}
+	if err := ApplyEnv(&conf); err != nil {
+		t.Fatal(err)
+	}
+	want := envConfig{Addr: ":8080", Workers: 4, Token: "s3cr3t", Debug: true}
+	if conf != want {
+		t.Fatalf("got %+v, want %+v", conf, want)
+	}
+	setenv(t, map[string]string{"MYAPP_WORKERS": "many"})
+	if err := ApplyEnv(&conf); err == nil {
+		t.Fatal("invalid value should fail")
+	}
+}
+
+func TestApplyEnvNoPrefix(t *testing.T) {
+	setenv(t, map[string]string{"NAME": "ignored", "APP_LEVEL": "3"})
+	conf := struct {
+		Name  string `flag:"name"`
+		Level int    `flag:"level,,env=APP_LEVEL"`
+	}{}
+	if err := ApplyEnv(&conf); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Name != "" || conf.Level != 3 {
+		t.Fatalf("unexpected result: %+v", conf)
+	}
+}
+
+func TestApplyEnvWithPrefix(t *testing.T) {
+	setenv(t, map[string]string{
+		"APP_SERVER_PORT": "8080",
+		"APP_DB_HOST":     "db.local",
+		"app.cache.size":  "64",
+		"APP_CACHE_SIZE":  "1",
+	})
+	type conf struct {
+		Port int `flag:"server-port"`
+		DB   struct {
+			Host string `flag:"db.host"`
+		}
+		CacheSize int `flag:"cache.size"`
+	}
+	var c conf
+	if err := ApplyEnvWithPrefix(&c, "APP_", nil); err != nil {
+		t.Fatal(err)
+	}
+	if c.Port != 8080 || c.DB.Host != "db.local" || c.CacheSize != 1 {
+		t.Fatalf("unexpected result: %+v", c)
+	}
+	c = conf{}
+	if err := ApplyEnvWithPrefix(&c, "app.", func(name string) string { return name }); err != nil {
+		t.Fatal(err)
+	}
+	if c.Port != 0 || c.DB.Host != "" || c.CacheSize != 64 {
+		t.Fatalf("unexpected result with custom mapper: %+v", c)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	for _, name := range []string{"env-token.txt", "arg-token.txt"} {
+		if err := os.WriteFile(name, []byte(name+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	type config struct {
+		Token string `flag:"token,,fromfile=token.txt,env=TEST_RESOLVE_TOKEN"`
+		Addr  string `flag:"addr,,env=TEST_RESOLVE_ADDR"`
+	}
+	for _, c := range []struct {
+		arg, file, env bool
+		want           string
+	}{
+		{want: "default"},
+		{env: true, want: "env-token.txt"},
+		{file: true, want: "token.txt"},
+		{file: true, env: true, want: "token.txt"},
+		{arg: true, want: "arg-token.txt"},
+		{arg: true, env: true, want: "arg-token.txt"},
+		{arg: true, file: true, want: "arg-token.txt"},
+		{arg: true, file: true, env: true, want: "arg-token.txt"},
+	} {
+		os.Remove("token.txt")
+		if c.file {
+			if err := os.WriteFile("token.txt", []byte("token.txt\n"), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		os.Unsetenv("TEST_RESOLVE_TOKEN")
+		if c.env {
+			setenv(t, map[string]string{"TEST_RESOLVE_TOKEN": "env-token.txt"})
+		}
+		var args []string
+		if c.arg {
+			args = []string{"-token", "arg-token.txt"}
+		}
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		conf := config{Token: "default"}
+		DefineFlagSet(fs, &conf)
+		if err := fs.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		if err := Resolve(fs, &conf); err != nil {
+			t.Fatalf("%+v: %v", c, err)
+		}
+		if conf.Token != c.want {
+			t.Errorf("%+v: got %q, want %q", c, conf.Token, c.want)
+		}
+	}
+
+	setenv(t, map[string]string{"TEST_RESOLVE_ADDR": ":8080"})
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := config{Addr: ":80"}
+	DefineFlagSet(fs, &conf)
+	if err := fs.Parse([]string{"-addr", ":9090"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Resolve(fs, &conf); err != nil || conf.Addr != ":9090" {
+		t.Fatalf("explicit flag overridden: %v, %q", err, conf.Addr)
+	}
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	conf = config{Addr: ":80"}
+	DefineFlagSet(fs, &conf)
+	if err := Resolve(fs, &conf); err != nil || conf.Addr != ":8080" {
+		t.Fatalf("environment not applied: %v, %q", err, conf.Addr)
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	for in, want := range map[string]string{
+		"server.listen-addr": "SERVER_LISTEN_ADDR",
+		"MaxRetries":         "MAX_RETRIES",
+		"HTTPPort":           "HTTP_PORT",
+		"HTTPSProxyURL":      "HTTPS_PROXY_URL",
+		"HTTP2Server":        "HTTP2_SERVER",
+		"ID":                 "ID",
+		"userID":             "USER_ID",
+		"Server.MaxConns":    "SERVER_MAX_CONNS",
+		"v":                  "V",
+	} {
+		if got := EnvName(in); got != want {
+			t.Errorf("EnvName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestDefineFromEnv(t *testing.T) {
+	setenv(t, map[string]string{
+		"APP_MAX_RETRIES": "5",
+		"APP_HTTP_PORT":   "8080",
+		"CUSTOM_NAME":     "x",
+	})
+	conf := struct {
+		MaxRetries int    `flag:"retries"`
+		Name       string `flag:"name,,env=CUSTOM_NAME"`
+		HTTP       struct {
+			Port int `flag:"port"`
+		}
+	}{}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	if err := DefineFromEnv(fs, &conf, "APP_"); err != nil {
+		t.Fatal(err)
+	}
+	if conf.MaxRetries != 5 || conf.Name != "x" || conf.HTTP.Port != 8080 {
+		t.Fatalf("unexpected result: %+v", conf)
+	}
+	if got := fs.Lookup("port").DefValue; got != "8080" {
+		t.Fatalf("unexpected default: %q", got)
+	}
+}
@@ -1,3 +1,10 @@
 module github.com/artyom/autoflags
 
 go 1.16
+
+require (
+	github.com/pelletier/go-toml/v2 v2.1.0
+	github.com/spf13/pflag v1.0.5
+	golang.org/x/sys v0.0.0-20210423082822-04245dca01da // indirect
+	golang.org/x/term v0.0.0-20210422114643-f5beecf764ed
+)
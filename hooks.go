@@ -0,0 +1,126 @@
+package autoflags
+
+import (
+	"context"
+	"flag"
+	"reflect"
+)
+
+// PostParser is implemented by config structs that need to adjust their
+// fields after flags are parsed, e.g. to resolve relative paths.
+type PostParser interface {
+	FlagPostParse() error
+}
+
+// PostParse is supposed to be called after flags are parsed, it calls
+// FlagPostParse method of config and all its nested structs implementing
+// [PostParser], nested structs are processed before the struct they belong to.
+// Nested structs are untagged exported fields of struct types or non-nil
+// pointers to structs not implementing flag.Value; each struct is processed
+// once, even if multiple pointers lead to it. All methods are called even if
+// some of them fail, the first error is returned.
+func PostParse(config interface{}) error {
+	st, err := structValue(config)
+	if err != nil {
+		return err
+	}
+	return postParse(st, make(map[interface{}]bool))
+}
+
+// postParse calls FlagPostParse methods of st and its nested structs, seen
+// holds pointers to structs already processed
+func postParse(st reflect.Value, seen map[interface{}]bool) error {
+	p := st.Addr().Interface()
+	if seen[p] {
+		return nil
+	}
+	seen[p] = true
+	var firstErr error
+	for i := 0; i < st.NumField(); i++ {
+		typ := st.Type().Field(i)
+		if typ.Tag.Get(defaultDefiner.tagKey()) != "" {
+			continue
+		}
+		val, ok := nestedStruct(st.Field(i), typ)
+		if !ok {
+			continue
+		}
+		if err := postParse(val, seen); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if p, ok := st.Addr().Interface().(PostParser); ok {
+		if err := p.FlagPostParse(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// nestedStruct returns struct value of untagged field v which is treated as
+// a nested struct: either exported field of struct type or non-nil pointer to
+// struct, as long as it does not implement flag.Value.
+func nestedStruct(v reflect.Value, typ reflect.StructField) (reflect.Value, bool) {
+	if typ.PkgPath != "" {
+		return v, false
+	}
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct || !v.CanAddr() || v.Addr().Type().Implements(flagValueType) {
+		return v, false
+	}
+	return v, true
+}
+
+// ContextDefaulter is implemented by config structs whose defaults depend on
+// a context, like deadlines or endpoints discovered with request-scoped data.
+type ContextDefaulter interface {
+	FlagDefaults(context.Context)
+}
+
+// DefineFlagSetContext works like [DefineFlagSet], but before defining flags
+// it calls FlagDefaults method of config and all its nested structs
+// implementing [ContextDefaulter] with ctx, nested structs are processed
+// before the struct they belong to, they are found the same way as for
+// [PostParse]. Fields set by these methods become flag defaults. If ctx is
+// done once methods are called, no flags are defined and ctx error is
+// returned. Unlike DefineFlagSet, it returns an error instead of panicking.
+func DefineFlagSetContext(ctx context.Context, fs *flag.FlagSet, config interface{}) error {
+	if fs == nil {
+		return errInvalidFlagSet
+	}
+	st, err := structValue(config)
+	if err != nil {
+		return err
+	}
+	contextDefaults(ctx, st, make(map[interface{}]bool))
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return defaultDefiner.defineFlagSet(fs, config, false)
+}
+
+// contextDefaults calls FlagDefaults methods of st and its nested structs,
+// seen holds pointers to structs already processed
+func contextDefaults(ctx context.Context, st reflect.Value, seen map[interface{}]bool) {
+	p := st.Addr().Interface()
+	if seen[p] {
+		return
+	}
+	seen[p] = true
+	for i := 0; i < st.NumField(); i++ {
+		typ := st.Type().Field(i)
+		if typ.Tag.Get(defaultDefiner.tagKey()) != "" {
+			continue
+		}
+		val, ok := nestedStruct(st.Field(i), typ)
+		if !ok {
+			continue
+		}
+		contextDefaults(ctx, val, seen)
+	}
+	if d, ok := st.Addr().Interface().(ContextDefaulter); ok {
+		d.FlagDefaults(ctx)
+	}
+}
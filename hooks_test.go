@@ -0,0 +1,118 @@
+package autoflags
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type postParseConfig struct {
+	Dir    string `flag:"dir"`
+	Inner  postParseInner
+	Ptr    *postParseInner
+	Same   *postParseInner
+	Parent *postParseConfig
+	Value  *postParseValue
+	calls  []string
+}
+
+func (c *postParseConfig) FlagPostParse() error {
+	c.calls = append(c.calls, "outer")
+	abs, err := filepath.Abs(c.Dir)
+	c.Dir = abs
+	return err
+}
+
+type postParseInner struct {
+	Fail  bool
+	calls *[]string
+}
+
+func (c *postParseInner) FlagPostParse() error {
+	*c.calls = append(*c.calls, "inner")
+	if c.Fail {
+		return errors.New("inner failed")
+	}
+	return nil
+}
+
+// postParseValue implements flag.Value, so it is not walked by PostParse
+type postParseValue struct{ postParseInner }
+
+func (*postParseValue) String() string   { return "" }
+func (*postParseValue) Set(string) error { return nil }
+
+func TestPostParse(t *testing.T) {
+	conf := &postParseConfig{Dir: "data"}
+	conf.Inner.calls = &conf.calls
+	conf.Ptr = &postParseInner{Fail: true, calls: &conf.calls}
+	conf.Same, conf.Parent = conf.Ptr, conf
+	conf.Value = &postParseValue{postParseInner{calls: &conf.calls}}
+	err := PostParse(conf)
+	if err == nil || err.Error() != "inner failed" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"inner", "inner", "outer"}; !reflect.DeepEqual(conf.calls, want) {
+		t.Fatalf("got calls %q, want %q", conf.calls, want)
+	}
+	if !filepath.IsAbs(conf.Dir) {
+		t.Fatalf("Dir is not absolute: %q", conf.Dir)
+	}
+}
+
+type contextKey struct{}
+
+type contextConfig struct {
+	Endpoint string        `flag:"endpoint"`
+	Timeout  time.Duration `flag:"timeout"`
+	Inner    contextInner
+}
+
+func (c *contextConfig) FlagDefaults(ctx context.Context) {
+	c.Endpoint, _ = ctx.Value(contextKey{}).(string)
+	if deadline, ok := ctx.Deadline(); ok {
+		c.Timeout = time.Until(deadline).Round(time.Hour)
+	}
+}
+
+type contextInner struct {
+	Trace string `flag:"trace"`
+}
+
+func (c *contextInner) FlagDefaults(ctx context.Context) { c.Trace = "trace-1" }
+
+func TestDefineFlagSetContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Hour)
+	defer cancel()
+	ctx = context.WithValue(ctx, contextKey{}, "http://10.0.0.1:8080")
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := &contextConfig{}
+	if err := DefineFlagSetContext(ctx, fs, conf); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"endpoint": "http://10.0.0.1:8080",
+		"timeout":  "2h0m0s",
+		"trace":    "trace-1",
+	} {
+		if got := fs.Lookup(name).DefValue; got != want {
+			t.Errorf("flag -%s: got default %q, want %q", name, got, want)
+		}
+	}
+	if err := fs.Parse([]string{"-endpoint", "http://localhost"}); err != nil || conf.Endpoint != "http://localhost" {
+		t.Fatalf("unexpected result: %q, %v", conf.Endpoint, err)
+	}
+
+	cancel()
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	if err := DefineFlagSetContext(ctx, fs, &contextConfig{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fs.Lookup("endpoint") != nil {
+		t.Fatal("flags should not be defined for canceled context")
+	}
+}
@@ -0,0 +1,103 @@
+package autoflags
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// implRegistry keeps implementations of interfaces registered with
+// RegisterInterfaceImpl, keyed by interface type
+var implRegistry = struct {
+	sync.Mutex
+	impls map[reflect.Type][]impl
+}{impls: make(map[reflect.Type][]impl)}
+
+// impl is a named implementation of an interface
+type impl struct {
+	name    string
+	typ     reflect.Type // type of values factory returns
+	factory func() interface{}
+}
+
+// RegisterInterfaceImpl registers factory of the interface implementation
+// under the given name, so that flag-tagged fields of that interface type can
+// be set by the name:
+//
+//	autoflags.RegisterInterfaceImpl((*Backend)(nil), "redis",
+//		func() interface{} { return &RedisBackend{} })
+//
+// With the above, for a field
+//
+//	Store Backend `flag:"store"`
+//
+// -store=redis sets the field to a new value returned by factory. The
+// interface is given by ifaceSample, which must be a nil pointer to the
+// interface type. Factory is called once during registration to learn the
+// type of values it returns, which must implement the interface.
+// RegisterInterfaceImpl panics if arguments are invalid or name is already
+// registered for the interface; it is meant to be called from init functions.
+func RegisterInterfaceImpl(ifaceSample interface{}, name string, factory func() interface{}) {
+	t := reflect.TypeOf(ifaceSample)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Interface {
+		panic("autoflags: RegisterInterfaceImpl: ifaceSample must be a pointer to interface")
+	}
+	iface := t.Elem()
+	if name == "" || factory == nil {
+		panic("autoflags: RegisterInterfaceImpl: non-empty name and non-nil factory expected")
+	}
+	typ := reflect.TypeOf(factory())
+	if typ == nil || !typ.Implements(iface) {
+		panic(fmt.Sprintf("autoflags: RegisterInterfaceImpl: factory of %q returns %v not implementing %s",
+			name, typ, iface))
+	}
+	implRegistry.Lock()
+	defer implRegistry.Unlock()
+	for _, im := range implRegistry.impls[iface] {
+		if im.name == name {
+			panic(fmt.Sprintf("autoflags: RegisterInterfaceImpl: %q is already registered for %s", name, iface))
+		}
+	}
+	implRegistry.impls[iface] = append(implRegistry.impls[iface], impl{name: name, typ: typ, factory: factory})
+}
+
+// interfaceImpls returns implementations registered for interface type iface
+func interfaceImpls(iface reflect.Type) []impl {
+	implRegistry.Lock()
+	defer implRegistry.Unlock()
+	return append([]impl(nil), implRegistry.impls[iface]...)
+}
+
+// interfaceValue is a flag.Value for interface fields, it takes names of
+// implementations registered with RegisterInterfaceImpl
+type interfaceValue struct {
+	v     reflect.Value
+	impls []impl
+}
+
+func (i *interfaceValue) String() string {
+	if !i.v.IsValid() || i.v.IsNil() {
+		return ""
+	}
+	typ := i.v.Elem().Type()
+	for _, im := range i.impls {
+		if im.typ == typ {
+			return im.name
+		}
+	}
+	return typ.String()
+}
+
+func (i *interfaceValue) Set(s string) error {
+	names := make([]string, len(i.impls))
+	for n, im := range i.impls {
+		if im.name == s {
+			i.v.Set(reflect.ValueOf(im.factory()))
+			return nil
+		}
+		names[n] = im.name
+	}
+	return fmt.Errorf("unknown %s implementation %q, registered ones are: %s",
+		i.v.Type(), s, strings.Join(names, ", "))
+}
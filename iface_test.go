@@ -0,0 +1,68 @@
+package autoflags
+
+import (
+	"flag"
+	"testing"
+)
+
+type testBackend interface{ Kind() string }
+
+type redisBackend struct{}
+
+func (*redisBackend) Kind() string { return "redis" }
+
+type memBackend struct{}
+
+func (memBackend) Kind() string { return "memory" }
+
+func init() {
+	RegisterInterfaceImpl((*testBackend)(nil), "redis", func() interface{} { return &redisBackend{} })
+	RegisterInterfaceImpl((*testBackend)(nil), "memory", func() interface{} { return memBackend{} })
+}
+
+func TestInterfaceField(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Store testBackend `flag:"store"`
+	}{Store: memBackend{}}
+	DefineFlagSet(fs, &conf)
+	if got := fs.Lookup("store").DefValue; got != "memory" {
+		t.Fatalf("unexpected default: %q", got)
+	}
+	if err := fs.Parse([]string{"-store", "redis"}); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Store == nil || conf.Store.Kind() != "redis" {
+		t.Fatalf("unexpected value: %#v", conf.Store)
+	}
+	err := fs.Set("store", "disk")
+	want := `unknown autoflags.testBackend implementation "disk", registered ones are: redis, memory`
+	if err == nil || err.Error() != want {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	type unknownIface interface{ Unknown() }
+	if err := DefineFlagSetStrict(flag.NewFlagSet("test", flag.ContinueOnError), &struct {
+		X unknownIface `flag:"x"`
+	}{}); err == nil {
+		t.Fatal("interface without registered implementations should be unsupported")
+	}
+}
+
+func TestRegisterInterfaceImplPanics(t *testing.T) {
+	for _, f := range []func(){
+		func() { RegisterInterfaceImpl(testBackend(nil), "x", func() interface{} { return memBackend{} }) },
+		func() { RegisterInterfaceImpl((*testBackend)(nil), "x", func() interface{} { return 1 }) },
+		func() {
+			RegisterInterfaceImpl((*testBackend)(nil), "redis", func() interface{} { return memBackend{} })
+		},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("RegisterInterfaceImpl should panic")
+				}
+			}()
+			f()
+		}()
+	}
+}
@@ -0,0 +1,106 @@
+package autoflags
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// SaveJSON writes current values of config to w as indented JSON. It is meant
+// to be called after flags are parsed to dump the effective configuration.
+// Fields are encoded following [encoding/json] rules, so `json` tags are
+// respected if present, otherwise field names are used; nested structs are
+// encoded as nested objects. Values of fields with censor option are masked,
+// config itself is not modified. See package
+// [github.com/artyom/autoflags/autotoml] for TOML output.
+func SaveJSON(config interface{}, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(Censored(config))
+}
+
+// ApplyDefaults reads JSON object mapping flag names to values from r and sets
+// them as new defaults of the corresponding flags defined on fs; keys not
+// matching any flag are ignored. It is supposed to be called before fs is
+// parsed, so that command line flags can override such defaults:
+//
+//	{"listen-addr": ":9090", "workers": 4, "tag": ["a", "b"]}
+//
+// Values may be JSON strings, numbers, booleans, or arrays of them, elements
+// of which are set one by one, as if the flag was repeated. Unlike
+// [flag.FlagSet.Set], ApplyDefaults does not mark flags as set, and it updates
+// defaults shown in usage.
+func ApplyDefaults(fs *flag.FlagSet, r io.Reader) error {
+	var values map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&values); err != nil {
+		return fmt.Errorf("autoflags: decoding defaults: %w", err)
+	}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		f := fs.Lookup(k)
+		if f == nil {
+			continue
+		}
+		args, err := jsonArgs(values[k])
+		if err != nil {
+			return fmt.Errorf("autoflags: default for flag %s: %w", k, err)
+		}
+		for _, arg := range args {
+			if err := f.Value.Set(arg); err != nil {
+				return fmt.Errorf("autoflags: invalid default %q for flag %s: %w", censoredArg(fs, k, arg), k, err)
+			}
+		}
+		// so that the first command line flag replaces accumulated default
+		markDefault(f.Value)
+		f.DefValue = f.Value.String()
+		maskDefault(f, flagInfoMap(fs)[k])
+	}
+	return nil
+}
+
+// jsonArgs converts JSON value into flag arguments
+func jsonArgs(raw json.RawMessage) ([]string, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) != 0 && raw[0] == '[' {
+		var elems []json.RawMessage
+		if err := json.Unmarshal(raw, &elems); err != nil {
+			return nil, err
+		}
+		var args []string
+		for _, elem := range elems {
+			arg, err := jsonArg(elem)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+		}
+		return args, nil
+	}
+	arg, err := jsonArg(raw)
+	if err != nil {
+		return nil, err
+	}
+	return []string{arg}, nil
+}
+
+// jsonArg converts scalar JSON value into flag argument
+func jsonArg(raw json.RawMessage) (string, error) {
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return "", err
+	}
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool, float64:
+		return string(bytes.TrimSpace(raw)), nil
+	}
+	return "", fmt.Errorf("unsupported JSON value %s", raw)
+}
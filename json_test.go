@@ -0,0 +1,70 @@
+package autoflags
+
+import (
+	"bytes"
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSaveJSON(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Name string `flag:"name" json:"name"`
+		Age  uint   `flag:"age"`
+		TLS  struct {
+			Cert string `json:"cert"`
+		} `json:"tls"`
+	}{Name: "John Doe", Age: 34}
+	DefineFlagSet(fs, &conf)
+	if err := fs.Parse([]string{"-name", "Jane Roe"}); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := SaveJSON(&conf, &buf); err != nil {
+		t.Fatal(err)
+	}
+	want := "{\n\t\"name\": \"Jane Roe\",\n\t\"Age\": 34,\n\t\"tls\": {\n\t\t\"cert\": \"\"\n\t}\n}\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestApplyDefaults(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Addr    string            `flag:"listen-addr"`
+		Workers int               `flag:"workers"`
+		Debug   bool              `flag:"debug"`
+		Tags    []string          `flag:"tag"`
+		Labels  map[string]string `flag:"label"`
+		Name    string            `flag:"name,,required"`
+	}{Addr: ":8080", Tags: []string{"x"}}
+	DefineFlagSet(fs, &conf)
+	input := `{"listen-addr": ":9090", "workers": 4, "debug": true, "tag": ["a", "b"], "label": ["env=dev"], "unknown": 1}`
+	if err := ApplyDefaults(fs, strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Addr != ":9090" || conf.Workers != 4 || !conf.Debug || !reflect.DeepEqual(conf.Tags, []string{"a", "b"}) {
+		t.Fatalf("unexpected result: %+v", conf)
+	}
+	if got := fs.Lookup("listen-addr").DefValue; got != ":9090" {
+		t.Fatalf("default not updated: %q", got)
+	}
+	if err := fs.Parse([]string{"-listen-addr", ":80", "-tag", "c", "-label", "tier=web"}); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Addr != ":80" || !reflect.DeepEqual(conf.Tags, []string{"c"}) ||
+		!reflect.DeepEqual(conf.Labels, map[string]string{"tier": "web"}) {
+		t.Fatalf("command line should override defaults: %+v", conf)
+	}
+	if got := MissingRequired(fs); !reflect.DeepEqual(got, []string{"name"}) {
+		t.Fatalf("MissingRequired returned %q", got)
+	}
+	for _, input := range []string{`{"workers": "many"}`, `{"workers": {}}`, `[1]`} {
+		if err := ApplyDefaults(fs, strings.NewReader(input)); err == nil {
+			t.Errorf("ApplyDefaults(%s) should fail", input)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package autoflags
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// LoadKV takes pointer to a struct and sets its flag-tagged fields from r
+// holding lines of the "flagname=value" form, values are parsed the same way
+// as command line flag arguments. Empty lines and lines starting with # are
+// skipped, white space around flag names and values is trimmed. Repeated names
+// are applied in order, as repeated flags would be.
+//
+// LoadKV reports an error listing names not corresponding to any flag, no
+// fields are set in this case. Like [Apply], it is meant to be called before
+// [Define], so that loaded values become defaults, and it applies defaults
+// given by tag options to config the same way.
+func LoadKV(config interface{}, r io.Reader) error {
+	type line struct {
+		n          int
+		key, value string
+	}
+	var lines []line
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		s := strings.TrimSpace(sc.Text())
+		if s == "" || strings.HasPrefix(s, "#") {
+			continue
+		}
+		i := strings.IndexByte(s, '=')
+		if i < 0 {
+			return fmt.Errorf("autoflags: line %d: key=value expected, got %q", n, s)
+		}
+		lines = append(lines, line{n: n, key: strings.TrimSpace(s[:i]), value: strings.TrimSpace(s[i+1:])})
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	keys := make([]string, len(lines))
+	for i, l := range lines {
+		keys[i] = l.key
+	}
+	names, err := unknownFlags(config, keys)
+	if err != nil {
+		return err
+	}
+	if len(names) != 0 {
+		isUnknown := make(map[string]bool, len(names))
+		for _, name := range names {
+			isUnknown[name] = true
+		}
+		var unknown []string
+		for _, l := range lines {
+			if isUnknown[l.key] {
+				unknown = append(unknown, fmt.Sprintf("%s (line %d)", l.key, l.n))
+			}
+		}
+		return fmt.Errorf("autoflags: unknown flags: %s", strings.Join(unknown, ", "))
+	}
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	defer Forget(fs)
+	if err := defaultDefiner.defineFlagSet(fs, config, false); err != nil {
+		return err
+	}
+	for _, l := range lines {
+		if err := setLenient(fs, l.key, l.value); err != nil {
+			return fmt.Errorf("autoflags: line %d: invalid value %q for flag %s: %w", l.n, censoredArg(fs, l.key, l.value), l.key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package autoflags
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadKV(t *testing.T) {
+	type conf struct {
+		Name    string        `flag:"name"`
+		Timeout time.Duration `flag:"timeout"`
+		Tags    []string      `flag:"tag"`
+	}
+	c := conf{Name: "John Doe"}
+	input := "# comment\n\ntimeout = 1m\ntag=a\ntag=b,c\n"
+	if err := LoadKV(&c, strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+	want := conf{Name: "John Doe", Timeout: time.Minute, Tags: []string{"a", "b", "c"}}
+	if !reflect.DeepEqual(c, want) {
+		t.Fatalf("got %+v, want %+v", c, want)
+	}
+	c = conf{}
+	err := LoadKV(&c, strings.NewReader("name=Jane\nextra=1\n"))
+	if err == nil || err.Error() != "autoflags: unknown flags: extra (line 2)" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.Name != "" {
+		t.Fatal("LoadKV should not set fields if there are unknown keys")
+	}
+	d := struct {
+		Dir   string `flag:"dir"`
+		Cache string `flag:"cache,,defaultfrom=Dir+/cache"`
+	}{Dir: "/var"}
+	if err := LoadKV(&d, strings.NewReader("extra=1\n")); err == nil || d.Cache != "" {
+		t.Fatalf("LoadKV should fail without applying defaults: %v, %+v", err, d)
+	}
+	for _, input := range []string{"name\n", "timeout=soon\n"} {
+		if err := LoadKV(&c, strings.NewReader(input)); err == nil {
+			t.Errorf("LoadKV should fail on %q", input)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package autoflags
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"reflect"
+)
+
+// Link defines on fs a flag with the given name which sets all values ptrs
+// point to, so that independent components can share a single flag:
+//
+//	err := autoflags.Link(fs, "region", &storage.Region, &metrics.Region)
+//
+// All ptrs must be non-nil pointers of the same type, which may be any type
+// supported for struct fields. Each value is parsed separately, so they don't
+// share memory even for maps and slices. The default shown in usage is the
+// value of the first pointer; use [flag.FlagSet.Lookup] to set flag usage.
+func Link(fs *flag.FlagSet, name string, ptrs ...interface{}) error {
+	if len(ptrs) == 0 {
+		return errors.New("autoflags: Link: at least one pointer expected")
+	}
+	if fs.Lookup(name) != nil {
+		return fmt.Errorf("autoflags: Link: flag -%s is already defined", name)
+	}
+	lv := &linkValue{}
+	var typ reflect.Type
+	for i, p := range ptrs {
+		addr := reflect.ValueOf(p)
+		if addr.Kind() != reflect.Ptr || addr.IsNil() {
+			return fmt.Errorf("autoflags: Link: argument %d is not a non-nil pointer", i+1)
+		}
+		if typ == nil {
+			typ = addr.Type()
+		} else if addr.Type() != typ {
+			return fmt.Errorf("autoflags: Link: pointers of mismatched types: %s and %s", typ, addr.Type())
+		}
+		v, err := newFieldValue(addr, tagSpec{name: name})
+		if err != nil {
+			return err
+		}
+		lv.values = append(lv.values, v)
+	}
+	fs.Var(lv, name, "")
+	return nil
+}
+
+// linkValue is a flag.Value created by Link, it sets all of its values
+type linkValue struct {
+	values []flag.Value
+}
+
+func (l *linkValue) String() string {
+	if len(l.values) == 0 {
+		return ""
+	}
+	return l.values[0].String()
+}
+
+func (l *linkValue) Set(s string) error {
+	for _, v := range l.values {
+		if err := v.Set(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *linkValue) Get() interface{} {
+	if len(l.values) == 0 {
+		return nil
+	}
+	if g, ok := l.values[0].(flag.Getter); ok {
+		return g.Get()
+	}
+	return l.values[0].String()
+}
+
+func (l *linkValue) IsBoolFlag() bool {
+	if len(l.values) == 0 {
+		return false
+	}
+	b, ok := l.values[0].(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
@@ -0,0 +1,43 @@
+package autoflags
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+)
+
+func TestLink(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	var storage, metrics struct {
+		Region string
+		Zones  []string
+		Debug  bool
+	}
+	storage.Region = "us-east-1"
+	if err := Link(fs, "region", &storage.Region, &metrics.Region); err != nil {
+		t.Fatal(err)
+	}
+	if err := Link(fs, "zone", &storage.Zones, &metrics.Zones); err != nil {
+		t.Fatal(err)
+	}
+	if err := Link(fs, "debug", &storage.Debug, &metrics.Debug); err != nil {
+		t.Fatal(err)
+	}
+	if got := fs.Lookup("region").DefValue; got != "us-east-1" {
+		t.Fatalf("unexpected default: %q", got)
+	}
+	if err := fs.Parse([]string{"-region", "eu-west-1", "-zone", "a,b", "-debug"}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(storage, metrics) || storage.Region != "eu-west-1" || !storage.Debug ||
+		!reflect.DeepEqual(storage.Zones, []string{"a", "b"}) {
+		t.Fatalf("unexpected values: %+v, %+v", storage, metrics)
+	}
+	var n int
+	if err := Link(fs, "mixed", &storage.Region, &n); err == nil {
+		t.Fatal("pointers of mismatched types should fail")
+	}
+	if err := Link(fs, "region", &n); err == nil {
+		t.Fatal("redefining flag should fail")
+	}
+}
@@ -0,0 +1,197 @@
+package autoflags
+
+import (
+	"flag"
+	"reflect"
+	"sync"
+)
+
+// flagInfo holds metadata about a flag registered by this package
+type flagInfo struct {
+	name   string
+	usage  string       // usage as registered
+	field  string       // name of the struct field, dot-separated for nested structs
+	typ    reflect.Type // type of the struct field
+	opts   tagOptions
+	config interface{} // pointer to config struct flag was defined for
+}
+
+// isSet reports whether flag was set by any of its names, seen holds names of
+// flags set
+func (info *flagInfo) isSet(seen map[string]bool) bool {
+	for _, name := range info.names() {
+		if seen[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// names returns flag name followed by its short name and alias, if any
+func (info *flagInfo) names() []string {
+	return tagSpec{name: info.name, opts: info.opts}.names()
+}
+
+// unlisted reports whether flag name should be omitted from usage, which is
+// the case for hidden flags and aliases
+func (info *flagInfo) unlisted(name string) bool {
+	if info == nil {
+		return false
+	}
+	return info.opts.has("hidden") || (name != info.name && name == info.opts["alias"])
+}
+
+// FlagInfo describes a flag defined by this package
+type FlagInfo struct {
+	Name     string       // flag name
+	Short    string       // short alias, if set with short option
+	Alias    string       // alias, if set with alias option
+	Usage    string       // usage string, with details derived from options
+	Default  string       // default value as text, masked for flags with censor option
+	Field    string       // name of the struct field, dot-separated for nested structs
+	Type     reflect.Type // type of the struct field
+	Required bool         // whether flag has required option
+	Group    string       // group flag belongs to
+	Hidden   bool         // whether flag has hidden option
+	Env      string       // environment variable, if set with env option
+}
+
+// public returns info as FlagInfo with the given default value
+func (info *flagInfo) public(def string) FlagInfo {
+	if info.censored() && def != "" {
+		def = censoredText
+	}
+	return FlagInfo{
+		Name:     info.name,
+		Short:    info.opts["short"],
+		Alias:    info.opts["alias"],
+		Usage:    info.usage,
+		Default:  def,
+		Field:    info.field,
+		Type:     info.typ,
+		Required: info.opts.has("required"),
+		Group:    info.opts["group"],
+		Hidden:   info.opts.has("hidden"),
+		Env:      info.opts["env"],
+	}
+}
+
+// History returns arguments the flag with the given name was set to, in order
+// of Set calls, including values overwritten by later ones. History is only
+// recorded for flags having history option:
+//
+//	Config string `flag:"config,,history"`
+//
+// History returns nil for other flags. Arguments rejected by the flag are not
+// recorded. For flags with censor option, each argument is masked.
+func History(fs *flag.FlagSet, name string) []string {
+	f := fs.Lookup(name)
+	if f == nil {
+		return nil
+	}
+	for v := f.Value; v != nil; {
+		if h, ok := v.(*historyValue); ok {
+			out := append([]string(nil), h.history...)
+			if flagInfoMap(fs)[name].censored() {
+				for i := range out {
+					out[i] = censoredText
+				}
+			}
+			return out
+		}
+		w, ok := v.(interface{ wrapped() flag.Value })
+		if !ok {
+			break
+		}
+		v = w.wrapped()
+	}
+	return nil
+}
+
+// Flags returns descriptions of flags defined on fs by this package in order
+// of their definition.
+func Flags(fs *flag.FlagSet) []FlagInfo {
+	var out []FlagInfo
+	for _, info := range flagInfos(fs) {
+		out = append(out, info.public(fs.Lookup(info.name).DefValue))
+	}
+	return out
+}
+
+// registry keeps metadata of flags defined by this package for each FlagSet,
+// so that it can be used after parsing
+var registry = struct {
+	sync.Mutex
+	sets map[*flag.FlagSet][]*flagInfo
+}{sets: make(map[*flag.FlagSet][]*flagInfo)}
+
+func record(fs *flag.FlagSet, info *flagInfo) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.sets[fs] = append(registry.sets[fs], info)
+}
+
+// flagInfos returns metadata of flags defined on fs in order of their
+// definition
+func flagInfos(fs *flag.FlagSet) []*flagInfo {
+	registry.Lock()
+	defer registry.Unlock()
+	return append([]*flagInfo(nil), registry.sets[fs]...)
+}
+
+// Forget drops metadata this package keeps for flags defined on fs, which is
+// used by functions like [CheckExclusive]. Such metadata is kept until Forget
+// is called, so programs that create FlagSets repeatedly should call it once
+// fs is no longer used.
+func Forget(fs *flag.FlagSet) {
+	registry.Lock()
+	defer registry.Unlock()
+	delete(registry.sets, fs)
+}
+
+// setFlags returns names of flags that were set on fs
+func setFlags(fs *flag.FlagSet) map[string]bool {
+	seen := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { seen[f.Name] = true })
+	return seen
+}
+
+// flagInfoMap returns metadata of flags defined on fs keyed by all flag names
+func flagInfoMap(fs *flag.FlagSet) map[string]*flagInfo {
+	infos := make(map[string]*flagInfo)
+	for _, info := range flagInfos(fs) {
+		for _, name := range info.names() {
+			infos[name] = info
+		}
+	}
+	return infos
+}
+
+// Undefine returns a new FlagSet with the same name and error handling as fs,
+// having all flags of fs except those defined by this package for config, so
+// that callers can replace fs with it when a component owning config is
+// unloaded. Config must be the same pointer flags were defined for. Flags are
+// carried over with their current values and defaults, along with metadata
+// recorded by this package; information about which flags were set by parsing
+// is not preserved, and neither are output and usage function of fs.
+func Undefine(fs *flag.FlagSet, config interface{}) *flag.FlagSet {
+	out := flag.NewFlagSet(fs.Name(), fs.ErrorHandling())
+	owned := make(map[string]bool)
+	for _, info := range flagInfos(fs) {
+		if info.config == config {
+			for _, name := range info.names() {
+				owned[name] = true
+			}
+			continue
+		}
+		record(out, info)
+	}
+	fs.VisitAll(func(f *flag.Flag) {
+		if owned[f.Name] {
+			return
+		}
+		out.Var(f.Value, f.Name, f.Usage)
+		out.Lookup(f.Name).DefValue = f.DefValue
+	})
+	return out
+}
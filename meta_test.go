@@ -0,0 +1,69 @@
+package autoflags
+
+import (
+	"flag"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestUndefine(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	verbose := fs.Bool("v", false, "verbose")
+	var host struct {
+		Addr string `flag:"addr"`
+	}
+	plugin := struct {
+		Cache string `flag:"cache,,short=c"`
+	}{Cache: "/tmp"}
+	DefineFlagSet(fs, &host)
+	DefineFlagSet(fs, &plugin)
+	if err := fs.Parse([]string{"-addr", ":80"}); err != nil {
+		t.Fatal(err)
+	}
+	fs = Undefine(fs, &plugin)
+	for _, name := range []string{"cache", "c"} {
+		if fs.Lookup(name) != nil {
+			t.Errorf("flag -%s should be undefined", name)
+		}
+	}
+	if f := fs.Lookup("addr"); f == nil || f.DefValue != "" || f.Value.String() != ":80" {
+		t.Fatalf("unexpected -addr flag: %+v", f)
+	}
+	if infos := Flags(fs); len(infos) != 1 || infos[0].Name != "addr" {
+		t.Fatalf("unexpected metadata: %+v", infos)
+	}
+	if err := fs.Parse([]string{"-v"}); err != nil || !*verbose {
+		t.Fatalf("flags not defined by this package should be kept: %v", err)
+	}
+	DefineFlagSet(fs, &plugin)
+}
+
+func TestHistory(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	conf := struct {
+		Config string `flag:"config,,history,short=c"`
+		Level  int    `flag:"level,,history,hidden"`
+		Name   string `flag:"name"`
+	}{}
+	DefineFlagSet(fs, &conf)
+	if err := fs.Parse([]string{"-config", "a.conf", "-level", "1", "-c", "b.conf", "-level", "2", "-name", "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Config != "b.conf" || conf.Level != 2 {
+		t.Fatalf("unexpected result: %+v", conf)
+	}
+	if got, want := History(fs, "config"), []string{"a.conf", "b.conf"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if err := fs.Set("level", "x"); err == nil {
+		t.Fatal("setting invalid value should fail")
+	}
+	if got, want := History(fs, "level"), []string{"1", "2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if got := History(fs, "name"); got != nil {
+		t.Fatalf("got %q for flag without history option", got)
+	}
+}
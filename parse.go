@@ -0,0 +1,278 @@
+package autoflags
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// FieldError is returned by [ParseWithFieldErrors] when flag value is
+// rejected, it names the flag and the struct field the flag was defined for.
+// Use [errors.As] to extract it:
+//
+//	var fe *autoflags.FieldError
+//	if errors.As(err, &fe) {
+//		log.Printf("bad value of %s: %v", fe.Field, fe.Err)
+//	}
+type FieldError struct {
+	Flag  string // flag name as given in arguments, without dash
+	Field string // struct field name, dot-separated for nested structs; empty for flags not defined by this package
+	Value string // rejected argument, masked for flags with censor option
+	Err   error  // error returned by flag value Set method
+}
+
+func (e *FieldError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("invalid value %q for flag -%s: %v", e.Value, e.Flag, e.Err)
+	}
+	return fmt.Sprintf("invalid value %q for flag -%s (field %s): %v", e.Value, e.Flag, e.Field, e.Err)
+}
+
+func (e *FieldError) Unwrap() error { return e.Err }
+
+// ParseWithFieldErrors parses args with fs like [flag.FlagSet.Parse] does,
+// but if a flag value is rejected, the returned error is a [*FieldError]
+// naming the flag and the struct field it was defined for. Flag package only
+// keeps text of such errors, so for the duration of parsing ParseWithFieldErrors
+// wraps values of all flags on fs to capture their errors, restoring them
+// before usage is printed and before it returns. Other errors, like unknown
+// flags, are returned as is.
+func ParseWithFieldErrors(fs *flag.FlagSet, args []string) error {
+	infos := flagInfoMap(fs)
+	var failed *FieldError
+	orig := make(map[*flag.Flag]flag.Value)
+	fs.VisitAll(func(f *flag.Flag) {
+		orig[f] = f.Value
+		fe := &FieldError{Flag: f.Name}
+		info := infos[f.Name]
+		if info != nil {
+			fe.Field = info.field
+		}
+		f.Value = &fieldErrorValue{wrapper: wrapper{f.Value}, proto: fe, failed: &failed, censored: info.censored()}
+	})
+	restore := func() {
+		for f, v := range orig {
+			f.Value = v
+		}
+	}
+	usage := fs.Usage
+	fs.Usage = func() {
+		restore()
+		if usage != nil {
+			usage()
+			return
+		}
+		// mimics flag.FlagSet.defaultUsage
+		if fs.Name() == "" {
+			fmt.Fprintf(fs.Output(), "Usage:\n")
+		} else {
+			fmt.Fprintf(fs.Output(), "Usage of %s:\n", fs.Name())
+		}
+		fs.PrintDefaults()
+	}
+	err := parseCensored(fs, args)
+	restore()
+	fs.Usage = usage
+	if err != nil && failed != nil {
+		return failed
+	}
+	return err
+}
+
+// fieldErrorValue wraps flag.Value during ParseWithFieldErrors, it records
+// the first error of its Set method as a copy of proto
+type fieldErrorValue struct {
+	wrapper
+	proto    *FieldError
+	failed   **FieldError
+	censored bool // whether argument must be masked
+}
+
+func (v *fieldErrorValue) Set(s string) error {
+	err := v.v.Set(s)
+	if err != nil && *v.failed == nil {
+		fe := *v.proto
+		fe.Value, fe.Err = s, err
+		if v.censored {
+			fe.Value = censoredText
+		}
+		*v.failed = &fe
+	}
+	return err
+}
+
+// ParseCaseInsensitive parses args with fs, matching flag names in args
+// case-insensitively, so that -Name and -NAME both set -name flag. It only
+// works for flags registered with lowercase names, like ones defined with
+// [Definer] having NameFunc set to [strings.ToLower].
+func ParseCaseInsensitive(fs *flag.FlagSet, args []string) error {
+	out := make([]string, len(args))
+	copy(out, args)
+	for i := 0; i < len(out); i++ {
+		name, value, hasValue, ok := splitFlag(out[i])
+		if !ok {
+			break
+		}
+		lower := strings.ToLower(name)
+		f := fs.Lookup(lower)
+		if f == nil {
+			continue
+		}
+		dashes := out[i][:strings.Index(out[i], name)]
+		out[i] = dashes + lower
+		if hasValue {
+			out[i] += "=" + value
+		} else if !isBoolFlag(f) {
+			i++ // skip flag value
+		}
+	}
+	return parseCensored(fs, out)
+}
+
+// ParseDotted parses args with fs, allowing map flags defined by this package
+// to be set with the key given as a dot-separated suffix of the flag name, so
+// that for the flag of field
+//
+//	Labels map[string]string `flag:"labels"`
+//
+// arguments "-labels.env prod -labels.tier=web" are the same as
+// "-labels env=prod -labels tier=web". The key is everything after the first
+// dot following the flag name, so "-labels.app.kubernetes.io/name x" sets
+// "app.kubernetes.io/name" key.
+//
+// Since flag package only accepts flags defined in advance, ParseDotted
+// rewrites such arguments into the regular form before calling fs.Parse, so
+// all limitations of the flag package still apply: arguments after the first
+// non-flag argument or "--" are not rewritten, and dotted names are not listed
+// in usage output. If a flag with the full dotted name is defined, it takes
+// precedence.
+func ParseDotted(fs *flag.FlagSet, args []string) error {
+	var out []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, value, hasValue, ok := splitFlag(arg)
+		if !ok {
+			out = append(out, args[i:]...)
+			break
+		}
+		if f := fs.Lookup(name); f != nil {
+			out = append(out, arg)
+			if !hasValue && !isBoolFlag(f) && i+1 < len(args) {
+				i++
+				out = append(out, args[i])
+			}
+			continue
+		}
+		prefix, key, mv := dottedMapFlag(fs, name)
+		if mv == nil || (!hasValue && i+1 >= len(args)) {
+			out = append(out, arg)
+			continue
+		}
+		if !hasValue {
+			i++
+			value = args[i]
+		}
+		dashes := arg[:strings.Index(arg, name)]
+		out = append(out, dashes+prefix, key+mv.sep+value)
+	}
+	return parseCensored(fs, out)
+}
+
+// ParseGNU parses args with fs accepting GNU-style long options: "--name=value"
+// and "--name value" are rewritten into "-name=value" and "-name value" forms
+// before calling fs.Parse, values following non-boolean flags are passed
+// through untouched even if they start with dashes, and "--" terminates flags.
+// Like with fs.Parse, arguments after the first non-flag one are not parsed.
+func ParseGNU(fs *flag.FlagSet, args []string) error {
+	var out []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, value, hasValue, ok := splitFlag(arg)
+		if !ok {
+			out = append(out, args[i:]...)
+			break
+		}
+		if hasValue {
+			out = append(out, "-"+name+"="+value)
+			continue
+		}
+		out = append(out, "-"+name)
+		if f := fs.Lookup(name); f != nil && !isBoolFlag(f) && i+1 < len(args) {
+			i++
+			out = append(out, args[i])
+		}
+	}
+	return parseCensored(fs, out)
+}
+
+// ParseLenient parses args with fs, skipping flags not defined on fs instead
+// of failing on them, and returns such arguments as given, like "-x" or
+// "--color=auto", so that callers can warn about them or pass them along.
+// Since it's not known whether unknown flags take values, arguments following
+// them are treated as the next flags or positional arguments, so unknown
+// flags with values are only skipped entirely in -name=value form. Other
+// errors, like invalid values, are returned as usual, and -h or -help
+// request help as with fs.Parse.
+func ParseLenient(fs *flag.FlagSet, args []string) (unknown []string, err error) {
+	var out []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, _, hasValue, ok := splitFlag(arg)
+		if !ok {
+			out = append(out, args[i:]...)
+			break
+		}
+		f := fs.Lookup(name)
+		if f == nil && name != "h" && name != "help" {
+			unknown = append(unknown, arg)
+			continue
+		}
+		out = append(out, arg)
+		if f != nil && !hasValue && !isBoolFlag(f) && i+1 < len(args) {
+			i++
+			out = append(out, args[i])
+		}
+	}
+	return unknown, parseCensored(fs, out)
+}
+
+// dottedMapFlag splits name of "flag.key" form into a name of the map flag
+// defined on fs and a key, or returns nil mapValue if there's no such flag
+func dottedMapFlag(fs *flag.FlagSet, name string) (prefix, key string, mv *mapValue) {
+	for i := 0; i < len(name); i++ {
+		if name[i] != '.' {
+			continue
+		}
+		f := fs.Lookup(name[:i])
+		if f == nil {
+			continue
+		}
+		if mv, ok := unwrapValue(f.Value).(*mapValue); ok && i+1 < len(name) {
+			return name[:i], name[i+1:], mv
+		}
+	}
+	return "", "", nil
+}
+
+// splitFlag splits command line argument of -name, --name, -name=value or
+// --name=value form. It reports false if arg is not a flag or is a "--"
+// terminator, after which flag package stops parsing flags.
+func splitFlag(arg string) (name, value string, hasValue, ok bool) {
+	if len(arg) < 2 || arg[0] != '-' || arg == "--" {
+		return "", "", false, false
+	}
+	name = strings.TrimPrefix(arg[1:], "-")
+	if name == "" || name[0] == '-' || name[0] == '=' {
+		return "", "", false, false
+	}
+	if i := strings.IndexByte(name, '='); i >= 0 {
+		return name[:i], name[i+1:], true, true
+	}
+	return name, "", false, true
+}
+
+// isBoolFlag reports whether flag can be used without an explicit value
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
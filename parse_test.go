@@ -0,0 +1,143 @@
+package autoflags
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCaseInsensitive(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Name    string `flag:"Name"`
+		Verbose bool   `flag:"Verbose"`
+		Count   int    `flag:"count"`
+	}{}
+	(&Definer{NameFunc: strings.ToLower}).DefineFlagSet(fs, &conf)
+	args := []string{"-NAME", "-Count", "-VERBOSE", "--COUNT=3", "Rest", "-NAME"}
+	if err := ParseCaseInsensitive(fs, args); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Name != "-Count" || !conf.Verbose || conf.Count != 3 {
+		t.Fatalf("unexpected result: %+v", conf)
+	}
+	if want := []string{"Rest", "-NAME"}; !reflect.DeepEqual(fs.Args(), want) {
+		t.Fatalf("got args %q, want %q", fs.Args(), want)
+	}
+}
+
+func TestParseDotted(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Labels map[string]string `flag:"labels"`
+		Limits map[string]int    `flag:"limit,,kvsep=:"`
+		Name   string            `flag:"name"`
+	}{}
+	DefineFlagSet(fs, &conf)
+	args := []string{
+		"-labels.env", "prod", "--labels.tier=web", "-labels.app.io/name", "x",
+		"-limit.cpu", "2", "-name", "-labels.no", "-labels", "k=v", "rest", "-labels.z", "1",
+	}
+	if err := ParseDotted(fs, args); err != nil {
+		t.Fatal(err)
+	}
+	wantLabels := map[string]string{"env": "prod", "tier": "web", "app.io/name": "x", "k": "v"}
+	if !reflect.DeepEqual(conf.Labels, wantLabels) {
+		t.Fatalf("got labels %v, want %v", conf.Labels, wantLabels)
+	}
+	if conf.Limits["cpu"] != 2 || conf.Name != "-labels.no" {
+		t.Fatalf("unexpected result: %+v", conf)
+	}
+	if want := []string{"rest", "-labels.z", "1"}; !reflect.DeepEqual(fs.Args(), want) {
+		t.Fatalf("got args %q, want %q", fs.Args(), want)
+	}
+	fs.SetOutput(io.Discard)
+	if err := ParseDotted(fs, []string{"-name.x", "y"}); err == nil {
+		t.Fatal("dotted name of non-map flag should fail")
+	}
+}
+
+func TestParseWithFieldErrors(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	buf := new(strings.Builder)
+	fs.SetOutput(buf)
+	conf := struct {
+		Server struct {
+			Port int `flag:"port,,short=p"`
+		}
+		Name string `flag:"name"`
+	}{}
+	DefineFlagSet(fs, &conf)
+	err := ParseWithFieldErrors(fs, []string{"-name", "x", "-p", "http"})
+	var fe *FieldError
+	if !errors.As(err, &fe) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fe.Flag != "p" || fe.Field != "Server.Port" || fe.Value != "http" || fe.Err == nil {
+		t.Fatalf("unexpected error fields: %+v", fe)
+	}
+	if want := `invalid value "http" for flag -p (field Server.Port): `; !strings.HasPrefix(err.Error(), want) {
+		t.Fatalf("unexpected error text: %v", err)
+	}
+	if !strings.Contains(buf.String(), "-port int") {
+		t.Fatalf("usage printed with wrapped values:\n%s", buf)
+	}
+	if _, ok := fs.Lookup("port").Value.(*fieldErrorValue); ok {
+		t.Fatal("flag values are not restored")
+	}
+	if err := ParseWithFieldErrors(fs, []string{"-unknown"}); err == nil || errors.As(err, &fe) {
+		t.Fatalf("unexpected error for unknown flag: %v", err)
+	}
+}
+
+func TestParseGNU(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	conf := struct {
+		Name    string `flag:"name"`
+		Offset  int    `flag:"offset"`
+		Verbose bool   `flag:"verbose,,short=v"`
+		Pattern string `flag:"pattern"`
+	}{}
+	DefineFlagSet(fs, &conf)
+	args := []string{"--name=Jane", "--offset", "-5", "-v", "--pattern", "--x", "--", "--rest"}
+	if err := ParseGNU(fs, args); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Name != "Jane" || conf.Offset != -5 || !conf.Verbose || conf.Pattern != "--x" {
+		t.Fatalf("unexpected result: %+v", conf)
+	}
+	if want := []string{"--rest"}; !reflect.DeepEqual(fs.Args(), want) {
+		t.Fatalf("got args %q, want %q", fs.Args(), want)
+	}
+	if err := ParseGNU(fs, []string{"--unknown"}); err == nil {
+		t.Fatal("unknown flag should fail")
+	}
+}
+
+func TestParseLenient(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	conf := struct {
+		Name    string `flag:"name"`
+		Verbose bool   `flag:"v"`
+	}{}
+	DefineFlagSet(fs, &conf)
+	args := []string{"-future", "-name", "-x", "--color=auto", "-v", "file", "-later"}
+	unknown, err := ParseLenient(fs, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"-future", "--color=auto"}; !reflect.DeepEqual(unknown, want) {
+		t.Fatalf("got unknown %q, want %q", unknown, want)
+	}
+	if conf.Name != "-x" || !conf.Verbose {
+		t.Fatalf("unexpected result: %+v", conf)
+	}
+	if want := []string{"file", "-later"}; !reflect.DeepEqual(fs.Args(), want) {
+		t.Fatalf("got args %q, want %q", fs.Args(), want)
+	}
+}
@@ -0,0 +1,81 @@
+package autoflags
+
+import (
+	"flag"
+	"reflect"
+)
+
+// BindPositional is supposed to be called after fs is parsed, it sets field
+// of config marked for positional arguments to a copy of fs.Args(). Such
+// field must be of []string type and have a tag with either "..." name or
+// positional option, it is not exposed as a flag:
+//
+//	Files []string `flag:"...,files to process"`
+//	Files []string `flag:",,positional"`
+//
+// Nested structs are searched too, only the first marked field is set.
+// BindPositional does nothing if config has no such field.
+func BindPositional(fs *flag.FlagSet, config interface{}) error {
+	st, err := structValue(config)
+	if err != nil {
+		return err
+	}
+	if v, ok := markedField(st, defaultDefiner.tagKey(), tagSpec.positional); ok {
+		v.Set(reflect.ValueOf(append([]string(nil), fs.Args()...)))
+	}
+	return nil
+}
+
+// BindAfterDoubleDash sets field of config marked for arguments following "--"
+// to the tail of args after the first "--" element, like arguments to pass to
+// a subprocess. Such field must be of []string type and have a tag with name
+// starting with "--", it is not exposed as a flag:
+//
+//	ExecArgs []string `flag:"--exec,command to run"`
+//
+// Args must be the original arguments fs was given, like os.Args[1:], since
+// fs.Args() does not tell whether positional arguments followed "--". Field
+// is set to nil if args have no "--"; note that "--" after the first
+// non-flag argument is found as well. Nested structs are searched too, only
+// the first marked field is set. BindAfterDoubleDash does nothing if config
+// has no such field.
+func BindAfterDoubleDash(config interface{}, args []string) error {
+	st, err := structValue(config)
+	if err != nil {
+		return err
+	}
+	v, ok := markedField(st, defaultDefiner.tagKey(), tagSpec.afterDoubleDash)
+	if !ok {
+		return nil
+	}
+	var tail []string
+	for i, arg := range args {
+		if arg == "--" {
+			tail = append([]string{}, args[i+1:]...)
+			break
+		}
+	}
+	v.Set(reflect.ValueOf(tail))
+	return nil
+}
+
+// markedField returns the first []string field of st which tag is reported
+// by marked
+func markedField(st reflect.Value, tagKey string, marked func(tagSpec) bool) (reflect.Value, bool) {
+	for i := 0; i < st.NumField(); i++ {
+		typ := st.Type().Field(i)
+		tag := typ.Tag.Get(tagKey)
+		if tag == "" {
+			if nested, ok := nestedStruct(st.Field(i), typ); ok {
+				if v, ok := markedField(nested, tagKey, marked); ok {
+					return v, true
+				}
+			}
+			continue
+		}
+		if typ.PkgPath == "" && typ.Type == stringSliceType && marked(parseTag(tag)) {
+			return st.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
@@ -0,0 +1,87 @@
+package autoflags
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+)
+
+func TestBindPositional(t *testing.T) {
+	conf := struct {
+		Verbose bool     `flag:"v"`
+		Files   []string `flag:"...,files to process"`
+	}{}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	if err := DefineFlagSetStrict(fs, &conf); err != nil {
+		t.Fatal(err)
+	}
+	if fs.Lookup("...") != nil {
+		t.Fatal("positional field should not be exposed as a flag")
+	}
+	if err := fs.Parse([]string{"-v", "a.txt", "b.txt"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := BindPositional(fs, &conf); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a.txt", "b.txt"}; !reflect.DeepEqual(conf.Files, want) {
+		t.Fatalf("got %q, want %q", conf.Files, want)
+	}
+
+	var nested struct {
+		Args struct {
+			Rest []string `flag:",,positional"`
+		}
+	}
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	DefineFlagSet(fs, &nested)
+	if err := fs.Parse([]string{"x"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := BindPositional(fs, &nested); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"x"}; !reflect.DeepEqual(nested.Args.Rest, want) {
+		t.Fatalf("got %q, want %q", nested.Args.Rest, want)
+	}
+
+	err := DefineFlagSetStrict(flag.NewFlagSet("test", flag.ContinueOnError), &struct {
+		Rest string `flag:"..."`
+	}{})
+	if err == nil {
+		t.Fatal("positional field of type other than []string should fail")
+	}
+}
+
+func TestBindAfterDoubleDash(t *testing.T) {
+	conf := struct {
+		Verbose  bool     `flag:"v"`
+		Files    []string `flag:"..."`
+		ExecArgs []string `flag:"--exec,command to run"`
+	}{}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	if err := DefineFlagSetStrict(fs, &conf); err != nil {
+		t.Fatal(err)
+	}
+	if fs.Lookup("--exec") != nil {
+		t.Fatal("field for arguments after -- should not be exposed as a flag")
+	}
+	args := []string{"-v", "--", "ls", "-l", "--", "x"}
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	if err := BindAfterDoubleDash(&conf, args); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"ls", "-l", "--", "x"}; !reflect.DeepEqual(conf.ExecArgs, want) {
+		t.Fatalf("got %q, want %q", conf.ExecArgs, want)
+	}
+	if err := BindAfterDoubleDash(&conf, []string{"-v"}); err != nil || conf.ExecArgs != nil {
+		t.Fatalf("got %q, %v for args without --", conf.ExecArgs, err)
+	}
+	if err := DefineFlagSetStrict(flag.NewFlagSet("test", flag.ContinueOnError), &struct {
+		Exec string `flag:"--exec"`
+	}{}); err == nil {
+		t.Fatal("non-slice field for arguments after -- should fail")
+	}
+}
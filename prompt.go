@@ -0,0 +1,53 @@
+package autoflags
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// promptValue is a flag.Value for string fields with prompt option, it reads
+// value from the terminal with echo disabled if argument is "-"
+type promptValue struct {
+	p    *string
+	name string // flag name used in the prompt
+}
+
+func (v *promptValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	return *v.p
+}
+
+func (v *promptValue) Set(s string) error {
+	if s != "-" {
+		*v.p = s
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "Enter value of -%s: ", v.name)
+	b, err := readPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return fmt.Errorf("reading value from terminal: %w", err)
+	}
+	*v.p = strings.TrimSuffix(string(b), "\r")
+	return nil
+}
+
+func (v *promptValue) Get() interface{} { return *v.p }
+
+// errNotTerminal is returned by readPassword if input is not a terminal
+var errNotTerminal = errors.New("standard input is not a terminal")
+
+// readPassword reads line from terminal fd with echo disabled, it returns
+// errNotTerminal if fd is not a terminal
+var readPassword = func(fd int) ([]byte, error) {
+	if !term.IsTerminal(fd) {
+		return nil, errNotTerminal
+	}
+	return term.ReadPassword(fd)
+}
@@ -0,0 +1,40 @@
+package autoflags
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"testing"
+)
+
+func TestPrompt(t *testing.T) {
+	defer func(fn func(int) ([]byte, error)) { readPassword = fn }(readPassword)
+	var calls int
+	readPassword = func(int) ([]byte, error) {
+		calls++
+		return []byte("s3cret"), nil
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	conf := struct {
+		Password string `flag:"password,,prompt"`
+		Token    string `flag:"token,,prompt"`
+	}{}
+	DefineFlagSet(fs, &conf)
+	if err := fs.Parse([]string{"-password", "-", "-token", "literal"}); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Password != "s3cret" || conf.Token != "literal" || calls != 1 {
+		t.Fatalf("unexpected result: %+v, prompted %d times", conf, calls)
+	}
+	if err := Apply(&conf, map[string]string{"password": ""}); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Password != "" || calls != 1 {
+		t.Fatalf("empty value should not prompt: %+v, prompted %d times", conf, calls)
+	}
+	readPassword = func(int) ([]byte, error) { return nil, errNotTerminal }
+	if err := fs.Set("password", "-"); !errors.Is(err, errNotTerminal) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package autoflags
+
+import (
+	"flag"
+	"fmt"
+	"sync"
+)
+
+// sectionRegistry keeps config sections registered with Register in order of
+// registration
+var sectionRegistry struct {
+	sync.Mutex
+	sections []section
+}
+
+// section is a named config registered with Register
+type section struct {
+	name   string
+	config interface{}
+}
+
+// Register adds config, a pointer to a struct, to the package-level registry
+// under the given name, so that flags for all registered configs can be
+// defined at once with [DefineAllRegistered]. It lets packages register their
+// own configs from init functions:
+//
+//	func init() { autoflags.Register("cache", &config) }
+//
+// Register panics if name is empty or already registered.
+func Register(name string, config interface{}) {
+	if name == "" {
+		panic("autoflags: Register: empty section name")
+	}
+	sectionRegistry.Lock()
+	defer sectionRegistry.Unlock()
+	for _, s := range sectionRegistry.sections {
+		if s.name == name {
+			panic(fmt.Sprintf("autoflags: Register: section %q is already registered", name))
+		}
+	}
+	sectionRegistry.sections = append(sectionRegistry.sections, section{name: name, config: config})
+}
+
+// DefineAllRegistered declares on fs flags for all configs added with
+// [Register], in order of their registration. All configs are checked before
+// any flag is defined, so if a config is invalid or a flag name is used by
+// more than one section, DefineAllRegistered returns an error leaving fs and
+// configs intact.
+func DefineAllRegistered(fs *flag.FlagSet) error { return defineRegistered(fs, false) }
+
+// DefineAllRegisteredPrefixed works like [DefineAllRegistered], but prefixes
+// flag names with their section name and a dot, so that field Size of config
+// registered as "cache" is set by -cache.size flag.
+func DefineAllRegisteredPrefixed(fs *flag.FlagSet) error { return defineRegistered(fs, true) }
+
+func defineRegistered(fs *flag.FlagSet, prefixed bool) error {
+	sectionRegistry.Lock()
+	sections := append([]section(nil), sectionRegistry.sections...)
+	sectionRegistry.Unlock()
+	definers := make([]*Definer, len(sections))
+	owners := make(map[string]string)
+	for i, s := range sections {
+		d := defaultDefiner
+		if prefixed {
+			d = WithPrefix(s.name + ".")
+		}
+		definers[i] = d
+		// configs are checked on copies, as defining flags applies
+		// defaults to fields
+		ff, err := d.configFields(fs, deepCopy(s.config), false)
+		if err != nil {
+			return err
+		}
+		if err := checkNames(fs, ff); err != nil {
+			return err
+		}
+		for _, f := range ff {
+			for _, name := range f.spec.names() {
+				if owner, ok := owners[name]; ok {
+					return fmt.Errorf("autoflags: flag -%s of section %q is already used by section %q",
+						name, s.name, owner)
+				}
+				owners[name] = s.name
+			}
+		}
+	}
+	for i, s := range sections {
+		fields, err := definers[i].configFields(fs, s.config, false)
+		if err != nil {
+			return err
+		}
+		defineFields(fs, fields, s.config)
+	}
+	return nil
+}
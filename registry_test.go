@@ -0,0 +1,95 @@
+package autoflags
+
+import (
+	"flag"
+	"testing"
+)
+
+// withSections runs fn with registry holding only sections registered by it
+func withSections(t *testing.T, fn func()) {
+	t.Helper()
+	sectionRegistry.Lock()
+	saved := sectionRegistry.sections
+	sectionRegistry.sections = nil
+	sectionRegistry.Unlock()
+	defer func() {
+		sectionRegistry.Lock()
+		sectionRegistry.sections = saved
+		sectionRegistry.Unlock()
+	}()
+	fn()
+}
+
+func TestDefineAllRegistered(t *testing.T) {
+	withSections(t, func() {
+		cache := struct {
+			Size int `flag:"size"`
+		}{}
+		db := struct {
+			Addr string `flag:"addr"`
+			Size int    `flag:"pool-size"`
+		}{}
+		Register("cache", &cache)
+		Register("db", &db)
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		if err := DefineAllRegistered(fs); err != nil {
+			t.Fatal(err)
+		}
+		if err := fs.Parse([]string{"-size", "10", "-addr", "db:5432"}); err != nil {
+			t.Fatal(err)
+		}
+		if cache.Size != 10 || db.Addr != "db:5432" {
+			t.Fatalf("unexpected result: %+v, %+v", cache, db)
+		}
+		fs = flag.NewFlagSet("test", flag.ContinueOnError)
+		if err := DefineAllRegisteredPrefixed(fs); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"cache.size", "db.addr", "db.pool-size"} {
+			if fs.Lookup(name) == nil {
+				t.Errorf("flag -%s is not defined", name)
+			}
+		}
+
+		Register("metrics", &struct {
+			Addr string `flag:"addr"`
+		}{})
+		fs = flag.NewFlagSet("test", flag.ContinueOnError)
+		err := DefineAllRegistered(fs)
+		want := `autoflags: flag -addr of section "metrics" is already used by section "db"`
+		if err == nil || err.Error() != want {
+			t.Fatalf("got error %v, want %q", err, want)
+		}
+		if fs.Lookup("size") != nil {
+			t.Fatal("flags defined despite an error")
+		}
+		if err := DefineAllRegisteredPrefixed(fs); err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			if recover() == nil {
+				t.Fatal("registering duplicate section should panic")
+			}
+		}()
+		Register("db", &db)
+	})
+}
+
+func TestDefineAllRegisteredUnmodified(t *testing.T) {
+	withSections(t, func() {
+		storage := struct {
+			Dir   string `flag:"dir"`
+			Cache string `flag:"cache,,defaultfrom=Dir+/cache"`
+		}{Dir: "/var"}
+		Register("storage", &storage)
+		Register("logs", &struct {
+			Dir string `flag:"dir"`
+		}{})
+		if err := DefineAllRegistered(flag.NewFlagSet("test", flag.ContinueOnError)); err == nil {
+			t.Fatal("DefineAllRegistered should fail on duplicate flags")
+		}
+		if storage.Cache != "" {
+			t.Fatalf("DefineAllRegistered applied defaults despite an error: %+v", storage)
+		}
+	})
+}
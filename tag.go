@@ -0,0 +1,301 @@
+package autoflags
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tagSpec is a parsed `flag` tag value
+type tagSpec struct {
+	name, usage string
+	opts        tagOptions
+}
+
+// tagOptions maps option names to their values, options without value map to
+// empty string
+type tagOptions map[string]string
+
+func (o tagOptions) has(name string) bool { _, ok := o[name]; return ok }
+
+// list returns value of the option split around "|"
+func (o tagOptions) list(name string) ([]string, bool) {
+	s, ok := o[name]
+	if !ok {
+		return nil, false
+	}
+	return strings.Split(s, "|"), true
+}
+
+// knownOptions lists all supported tag options
+var knownOptions = map[string]bool{
+	"fromfile":       true,
+	"text":           true,
+	"positional":     true,
+	"humanbool":      true,
+	"invert":         true,
+	"bits":           true,
+	"levels":         true,
+	"prompt":         true,
+	"template":       true,
+	"exclusive":      true,
+	"bitrate":        true,
+	"bytesize":       true,
+	"defaultunit":    true,
+	"kvsep":          true,
+	"pairs":          true,
+	"hidden":         true,
+	"epsilon":        true,
+	"sep":            true,
+	"fields":         true,
+	"split":          true,
+	"emptyutc":       true,
+	"when":           true,
+	"env":            true,
+	"defaultfrom":    true,
+	"required":       true,
+	"oneof":          true,
+	"unique":         true,
+	"short":          true,
+	"alias":          true,
+	"deprecated":     true,
+	"nonempty":       true,
+	"pattern":        true,
+	"sorted-set":     true,
+	"maxlen":         true,
+	"default":        true,
+	"minlen":         true,
+	"maxtotal":       true,
+	"nodefaultvalue": true,
+	"history":        true,
+	"censor":         true,
+	"once":           true,
+	"group":          true,
+}
+
+// parseTag parses tag of the "name,usage,option,option=value" form. If text
+// after the second comma is not a list of known options, it is treated as a
+// part of the usage string, so usage strings with commas keep working.
+func parseTag(tag string) tagSpec {
+	fields := strings.SplitN(tag, ",", 3)
+	spec := tagSpec{name: fields[0]}
+	if len(fields) > 1 {
+		spec.usage = fields[1]
+	}
+	if len(fields) == 3 {
+		if opts, ok := parseOptions(fields[2]); ok {
+			spec.opts = opts
+		} else {
+			spec.usage += "," + fields[2]
+		}
+	}
+	return spec
+}
+
+// commaOptions lists options which values may have commas: text following
+// such option up to the next known option is a part of its value
+var commaOptions = map[string]bool{
+	"sep":     true,
+	"split":   true,
+	"pattern": true,
+}
+
+// parseOptions parses comma-separated list of options, it reports false if s
+// has anything but known options.
+func parseOptions(s string) (tagOptions, bool) {
+	opts := make(tagOptions)
+	if s == "" {
+		return opts, true
+	}
+	var last string // last option, if it may have commas in its value
+	for _, opt := range strings.Split(s, ",") {
+		name, value := opt, ""
+		if i := strings.IndexByte(opt, '='); i >= 0 {
+			name, value = opt[:i], opt[i+1:]
+		}
+		if !knownOptions[name] {
+			if last == "" {
+				return nil, false
+			}
+			opts[last] += "," + opt
+			continue
+		}
+		last = ""
+		if commaOptions[name] && strings.IndexByte(opt, '=') >= 0 {
+			last = name
+		}
+		opts[name] = value
+	}
+	return opts, true
+}
+
+// check reports an error if options in spec are used incorrectly for a field
+// of type typ
+func (spec tagSpec) check(typ reflect.Type) error {
+	if group, ok := spec.opts["exclusive"]; ok && group == "" {
+		return fmt.Errorf("autoflags: flag %q: exclusive option requires group name", spec.name)
+	}
+	if group, ok := spec.opts["group"]; ok && group == "" {
+		return fmt.Errorf("autoflags: flag %q: group option requires name", spec.name)
+	}
+	for _, name := range []string{"kvsep", "pairs"} {
+		if spec.opts.has(name) && typ.Kind() != reflect.Map {
+			return fmt.Errorf("autoflags: flag %q: %s option requires map field", spec.name, name)
+		}
+	}
+	for _, name := range []string{"short", "alias", "deprecated", "kvsep", "env"} {
+		if v, ok := spec.opts[name]; ok && v == "" {
+			return fmt.Errorf("autoflags: flag %q: %s option requires value", spec.name, name)
+		}
+	}
+	if sep, ok := spec.opts["sep"]; ok && sep == "" {
+		return fmt.Errorf("autoflags: flag %q: sep option requires separator", spec.name)
+	}
+	for _, name := range sliceOptions {
+		if spec.opts.has(name) && typ.Kind() != reflect.Slice && typ.Kind() != reflect.Array {
+			return fmt.Errorf("autoflags: flag %q: %s option requires slice or array field", spec.name, name)
+		}
+	}
+	if spec.opts.has("emptyutc") && typ != reflect.TypeOf((*time.Location)(nil)) {
+		return fmt.Errorf("autoflags: flag %q: emptyutc option requires *time.Location field", spec.name)
+	}
+	if spec.opts.has("nonempty") && elemType(typ).Kind() != reflect.String {
+		return fmt.Errorf("autoflags: flag %q: nonempty option requires string field", spec.name)
+	}
+	if expr, ok := spec.opts["pattern"]; ok {
+		if elemType(typ).Kind() != reflect.String {
+			return fmt.Errorf("autoflags: flag %q: pattern option requires string field", spec.name)
+		}
+		if _, err := regexp.Compile(expr); err != nil || expr == "" {
+			return fmt.Errorf("autoflags: flag %q: pattern option requires valid regular expression", spec.name)
+		}
+	}
+	if k := elemType(typ).Kind(); spec.opts.has("oneof") && k != reflect.String && k != reflect.Float64 {
+		return fmt.Errorf("autoflags: flag %q: oneof option requires string or float64 field", spec.name)
+	}
+	if spec.opts.has("epsilon") && (!spec.opts.has("oneof") || elemType(typ).Kind() != reflect.Float64) {
+		return fmt.Errorf("autoflags: flag %q: epsilon option requires float64 field with oneof option", spec.name)
+	}
+	if spec.opts.has("unique") && typ.Kind() != reflect.Slice {
+		return fmt.Errorf("autoflags: flag %q: unique option requires slice field", spec.name)
+	}
+	for _, name := range []string{"maxlen", "minlen"} {
+		v, ok := spec.opts[name]
+		if !ok {
+			continue
+		}
+		if typ.Kind() != reflect.Slice {
+			return fmt.Errorf("autoflags: flag %q: %s option requires slice field", spec.name, name)
+		}
+		if n, err := strconv.Atoi(v); err != nil || n < 0 || (n == 0 && name == "maxlen") {
+			return fmt.Errorf("autoflags: flag %q: %s option requires positive number", spec.name, name)
+		}
+	}
+	if v, ok := spec.opts["maxtotal"]; ok {
+		if typ.Kind() != reflect.Slice || typ.Elem() != durationType {
+			return fmt.Errorf("autoflags: flag %q: maxtotal option requires slice of time.Duration", spec.name)
+		}
+		if d, err := time.ParseDuration(v); err != nil || d < 0 {
+			return fmt.Errorf("autoflags: flag %q: maxtotal option requires valid duration", spec.name)
+		}
+	}
+	if spec.opts.has("sorted-set") && (typ.Kind() != reflect.Slice ||
+		(typ.Elem().Kind() != reflect.String && typ.Elem().Kind() != reflect.Int)) {
+		return fmt.Errorf("autoflags: flag %q: sorted-set option requires slice of strings or ints", spec.name)
+	}
+	var splitOpts []string
+	for _, name := range sliceOptions {
+		if spec.opts.has(name) {
+			splitOpts = append(splitOpts, name)
+		}
+	}
+	if len(splitOpts) > 1 {
+		return fmt.Errorf("autoflags: flag %q: %s options are mutually exclusive", spec.name, strings.Join(splitOpts, " and "))
+	}
+	if expr, ok := spec.opts["split"]; ok {
+		if _, err := regexp.Compile(expr); err != nil || expr == "" {
+			return fmt.Errorf("autoflags: flag %q: split option requires valid regular expression", spec.name)
+		}
+	}
+	return nil
+}
+
+// conflictingOptions lists groups of options which can't be used together
+var conflictingOptions = [][]string{
+	// options defining how argument is parsed
+	{"fromfile", "prompt", "text", "template", "humanbool", "invert", "bits", "levels", "defaultunit", "bytesize", "bitrate"},
+	// required flag should be listed in help
+	{"required", "hidden"},
+	// sorted-set already implies unique elements
+	{"unique", "sorted-set"},
+}
+
+// checkConflicts reports an error if spec has more than one option from any
+// of the conflictingOptions groups
+func (spec tagSpec) checkConflicts() error {
+	for _, group := range conflictingOptions {
+		var found []string
+		for _, name := range group {
+			if spec.opts.has(name) {
+				found = append(found, name)
+			}
+		}
+		if len(found) > 1 {
+			return fmt.Errorf("autoflags: flag %q: options %s can't be used together",
+				spec.name, strings.Join(found, ", "))
+		}
+	}
+	return nil
+}
+
+// names returns flag name followed by its short name and alias, if any
+func (spec tagSpec) names() []string {
+	names := []string{spec.name}
+	for _, opt := range []string{"short", "alias"} {
+		if name := spec.opts[opt]; name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// positional reports whether tag marks field for positional arguments, either
+// with "..." name or positional option
+func (spec tagSpec) positional() bool {
+	return spec.name == "..." || spec.opts.has("positional")
+}
+
+// afterDoubleDash reports whether tag marks field for arguments following
+// "--", which is the case for names starting with "--", like "--exec"
+func (spec tagSpec) afterDoubleDash() bool {
+	return strings.HasPrefix(spec.name, "--")
+}
+
+// fullUsage returns usage string extended with details derived from options
+func (spec tagSpec) fullUsage() string {
+	choices, ok := spec.opts.list("oneof")
+	if !ok {
+		return spec.usage
+	}
+	s := "one of: " + strings.Join(choices, ", ")
+	if spec.usage == "" {
+		return s
+	}
+	return spec.usage + " (" + s + ")"
+}
+
+// elemType returns type of elements for slice, array and map types, or typ
+// itself for other types
+func elemType(typ reflect.Type) reflect.Type {
+	switch typ.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return typ.Elem()
+	}
+	return typ
+}
+
+// sliceOptions lists options only applicable to slice and array fields
+var sliceOptions = []string{"sep", "fields", "split"}
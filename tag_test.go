@@ -0,0 +1,75 @@
+package autoflags
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+)
+
+func TestParseTag(t *testing.T) {
+	for _, tc := range []struct {
+		tag, name, usage string
+		opts             int
+	}{
+		{"name", "name", "", 0},
+		{"name,usage string", "name", "usage string", 0},
+		{"name,usage, with commas", "name", "usage, with commas", 0},
+		{"name,,fromfile", "name", "", 1},
+		{"name,usage,fromfile", "name", "usage", 1},
+	} {
+		spec := parseTag(tc.tag)
+		if spec.name != tc.name || spec.usage != tc.usage || len(spec.opts) != tc.opts {
+			t.Errorf("parseTag(%q) = %+v", tc.tag, spec)
+		}
+	}
+}
+
+func TestParseTagCommas(t *testing.T) {
+	for _, tc := range []struct {
+		tag  string
+		want tagOptions
+	}{
+		{`tokens,,split=\s*,\s*`, tagOptions{"split": `\s*,\s*`}},
+		{`tags,,sep=,,unique`, tagOptions{"sep": ",", "unique": ""}},
+		{`tags,,unique,sep=,`, tagOptions{"sep": ",", "unique": ""}},
+		{`code,,pattern=^[a-z]{1,3}$,required`, tagOptions{"pattern": "^[a-z]{1,3}$", "required": ""}},
+	} {
+		spec := parseTag(tc.tag)
+		if spec.usage != "" || !reflect.DeepEqual(spec.opts, tc.want) {
+			t.Errorf("parseTag(%q) = %+v, want options %v", tc.tag, spec, tc.want)
+		}
+	}
+	if spec := parseTag("name,usage,unique, and more"); spec.usage != "usage,unique, and more" {
+		t.Errorf("unexpected usage %q", spec.usage)
+	}
+}
+
+func TestTagOptionConflicts(t *testing.T) {
+	for _, tc := range []struct {
+		config interface{}
+		want   string
+	}{
+		{&struct {
+			Debug bool `flag:"debug,,humanbool,invert"`
+		}{}, `autoflags: flag "debug": options humanbool, invert can't be used together`},
+		{&struct {
+			Token string `flag:"token,,required,hidden"`
+		}{}, `autoflags: flag "token": options required, hidden can't be used together`},
+		{&struct {
+			Tags []string `flag:"tag,,unique,sorted-set"`
+		}{}, `autoflags: flag "tag": options unique, sorted-set can't be used together`},
+	} {
+		err := DefineFlagSetStrict(flag.NewFlagSet("test", flag.ContinueOnError), tc.config)
+		if err == nil || err.Error() != tc.want {
+			t.Errorf("got error %v, want %q", err, tc.want)
+		}
+		// non-strict definition is not affected
+		DefineFlagSet(flag.NewFlagSet("test", flag.ContinueOnError), tc.config)
+	}
+	err := DefineFlagSetStrict(flag.NewFlagSet("test", flag.ContinueOnError), &struct {
+		Token string `flag:"token,,required,fromfile,group=auth"`
+	}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
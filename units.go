@@ -0,0 +1,240 @@
+package autoflags
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// rateUnits lists data rate suffixes in increasing order
+var rateUnits = []struct {
+	suffix string
+	mult   float64
+}{
+	{"bps", 1},
+	{"Kbps", 1e3},
+	{"Mbps", 1e6},
+	{"Gbps", 1e9},
+}
+
+// parseRate parses data rate like "10Mbps" or "1.5Gbps" into bits per second.
+// Number without suffix is treated as bits per second.
+func parseRate(s string) (uint64, error) {
+	num, mult := s, 1.0
+	for _, u := range rateUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			num, mult = strings.TrimSuffix(s, u.suffix), u.mult
+		}
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid data rate %q, number with one of bps, Kbps, Mbps, Gbps suffixes expected", s)
+	}
+	f *= mult
+	if f < 0 || f >= math.MaxUint64 || math.IsNaN(f) {
+		return 0, fmt.Errorf("data rate %q is out of range", s)
+	}
+	return uint64(math.Round(f)), nil
+}
+
+// formatRate renders bits per second using the largest suffix value fits
+func formatRate(n uint64) string {
+	u := rateUnits[0]
+	for _, unit := range rateUnits {
+		if float64(n) >= unit.mult {
+			u = unit
+		}
+	}
+	return strconv.FormatFloat(float64(n)/u.mult, 'f', -1, 64) + u.suffix
+}
+
+// rateValue is a flag.Value for uint64 or int64 fields with bitrate option
+type rateValue struct{ v reflect.Value }
+
+func newRateValue(v reflect.Value) (*rateValue, error) {
+	switch v.Kind() {
+	case reflect.Int64:
+		if v.Int() < 0 {
+			return nil, fmt.Errorf("data rate %d is negative", v.Int())
+		}
+		return &rateValue{v}, nil
+	case reflect.Uint64:
+		return &rateValue{v}, nil
+	}
+	return nil, errors.New("bitrate option requires uint64 or int64 field")
+}
+
+func (r *rateValue) String() string {
+	if !r.v.IsValid() {
+		return ""
+	}
+	if r.v.Kind() == reflect.Int64 {
+		return formatRate(uint64(r.v.Int()))
+	}
+	return formatRate(r.v.Uint())
+}
+
+func (r *rateValue) Set(s string) error {
+	n, err := parseRate(s)
+	if err != nil {
+		return err
+	}
+	if r.v.Kind() == reflect.Int64 {
+		if n > math.MaxInt64 {
+			return fmt.Errorf("data rate %q is out of range", s)
+		}
+		r.v.SetInt(int64(n))
+		return nil
+	}
+	r.v.SetUint(n)
+	return nil
+}
+
+// Bandwidth is a data rate in bits per second which can be used as a field
+// type without bitrate option. It takes values like 10Mbps or 1.5Gbps, see
+// bitrate option in package documentation for the list of suffixes; text form
+// uses the largest suffix, so it works the same way in flags, JSON or
+// environment variables.
+type Bandwidth uint64
+
+func (b Bandwidth) String() string { return formatRate(uint64(b)) }
+
+// Set implements [flag.Value] interface.
+func (b *Bandwidth) Set(s string) error {
+	n, err := parseRate(s)
+	if err != nil {
+		return err
+	}
+	*b = Bandwidth(n)
+	return nil
+}
+
+// Get implements [flag.Getter] interface.
+func (b *Bandwidth) Get() interface{} { return *b }
+
+// MarshalText implements [encoding.TextMarshaler] interface.
+func (b Bandwidth) MarshalText() ([]byte, error) { return []byte(b.String()), nil }
+
+// UnmarshalText implements [encoding.TextUnmarshaler] interface.
+func (b *Bandwidth) UnmarshalText(text []byte) error { return b.Set(string(text)) }
+
+// sizeUnits lists byte size suffixes, binary ones first, so that formatSize
+// prefers them
+var sizeUnits = []struct {
+	suffix string
+	mult   uint64
+}{
+	{"PiB", 1 << 50},
+	{"TiB", 1 << 40},
+	{"GiB", 1 << 30},
+	{"MiB", 1 << 20},
+	{"KiB", 1 << 10},
+	{"PB", 1e15},
+	{"TB", 1e12},
+	{"GB", 1e9},
+	{"MB", 1e6},
+	{"KB", 1e3},
+	{"kB", 1e3},
+	{"B", 1},
+}
+
+// parseSize parses byte size like "10MiB", "-1.5GB" or "512" (bytes) into its
+// sign and magnitude
+func parseSize(s string) (neg bool, n uint64, err error) {
+	num := s
+	switch {
+	case strings.HasPrefix(num, "-"):
+		neg, num = true, num[1:]
+	case strings.HasPrefix(num, "+"):
+		num = num[1:]
+	}
+	mult := uint64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(num, u.suffix) {
+			num, mult = strings.TrimSuffix(num, u.suffix), u.mult
+			break
+		}
+	}
+	if n, err := strconv.ParseUint(num, 10, 64); err == nil {
+		if n > math.MaxUint64/mult {
+			return neg, 0, fmt.Errorf("byte size %q is out of range", s)
+		}
+		return neg, n * mult, nil
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil || strings.HasPrefix(num, "-") || strings.HasPrefix(num, "+") {
+		return neg, 0, fmt.Errorf("invalid byte size %q, number with optional B, KB, KiB, MB, MiB, GB, GiB, TB, TiB, PB or PiB suffix expected", s)
+	}
+	f *= float64(mult)
+	if f >= math.MaxUint64 || math.IsNaN(f) || math.IsInf(f, 0) {
+		return neg, 0, fmt.Errorf("byte size %q is out of range", s)
+	}
+	return neg, uint64(math.Round(f)), nil
+}
+
+// formatSize renders byte size using the largest suffix value is a multiple
+// of, binary suffixes are preferred
+func formatSize(neg bool, n uint64) string {
+	sign := ""
+	if neg && n != 0 {
+		sign = "-"
+	}
+	for _, u := range sizeUnits {
+		if n != 0 && n%u.mult == 0 {
+			return sign + strconv.FormatUint(n/u.mult, 10) + u.suffix
+		}
+	}
+	return "0B"
+}
+
+// sizeValue is a flag.Value for int64 or uint64 fields with bytesize option,
+// int64 fields accept negative sizes
+type sizeValue struct{ v reflect.Value }
+
+func newSizeValue(v reflect.Value) (*sizeValue, error) {
+	switch v.Kind() {
+	case reflect.Uint64, reflect.Int64:
+		return &sizeValue{v}, nil
+	}
+	return nil, errors.New("bytesize option requires uint64 or int64 field")
+}
+
+func (s *sizeValue) String() string {
+	if !s.v.IsValid() {
+		return ""
+	}
+	if s.v.Kind() == reflect.Int64 {
+		n := s.v.Int()
+		if n < 0 {
+			return formatSize(true, uint64(-(n+1))+1)
+		}
+		return formatSize(false, uint64(n))
+	}
+	return formatSize(false, s.v.Uint())
+}
+
+func (s *sizeValue) Set(arg string) error {
+	neg, n, err := parseSize(arg)
+	if err != nil {
+		return err
+	}
+	if s.v.Kind() == reflect.Uint64 {
+		if neg && n != 0 {
+			return fmt.Errorf("negative byte size %q", arg)
+		}
+		s.v.SetUint(n)
+		return nil
+	}
+	switch {
+	case !neg && n > math.MaxInt64, neg && n > math.MaxInt64+1:
+		return fmt.Errorf("byte size %q is out of range", arg)
+	case neg:
+		s.v.SetInt(-int64(n-1) - 1)
+	default:
+		s.v.SetInt(int64(n))
+	}
+	return nil
+}
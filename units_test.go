@@ -0,0 +1,119 @@
+package autoflags
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"testing"
+)
+
+func TestRateValue(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Rate  uint64 `flag:"rate,,bitrate"`
+		Limit int64  `flag:"limit,,bitrate"`
+	}{Rate: 1500}
+	DefineFlagSet(fs, &conf)
+	if got := fs.Lookup("rate").DefValue; got != "1.5Kbps" {
+		t.Fatalf("unexpected default: %q", got)
+	}
+	if err := fs.Parse([]string{"-rate", "10Mbps", "-limit", "2.5Gbps"}); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Rate != 10e6 || conf.Limit != 25e8 {
+		t.Fatalf("unexpected values: %+v", conf)
+	}
+	if got := fs.Lookup("limit").Value.String(); got != "2.5Gbps" {
+		t.Fatalf("unexpected String() result: %q", got)
+	}
+	for _, arg := range []string{"10MBps", "fast", "-1Kbps"} {
+		if err := fs.Set("rate", arg); err == nil {
+			t.Errorf("setting %q should fail", arg)
+		}
+	}
+	defer func() {
+		want := `autoflags: flag "limit": data rate -5 is negative`
+		if x := recover(); x == nil || fmt.Sprint(x) != want {
+			t.Fatalf("got panic %v, want %q", x, want)
+		}
+	}()
+	DefineFlagSet(flag.NewFlagSet("test", flag.ContinueOnError), &struct {
+		Limit int64 `flag:"limit,,bitrate"`
+	}{Limit: -5})
+}
+
+func TestBandwidth(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Uplink Bandwidth `flag:"uplink"`
+	}{Uplink: 100e6}
+	DefineFlagSet(fs, &conf)
+	if got := fs.Lookup("uplink").DefValue; got != "100Mbps" {
+		t.Fatalf("unexpected default: %q", got)
+	}
+	if err := fs.Parse([]string{"-uplink", "1.5Gbps"}); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Uplink != 1.5e9 {
+		t.Fatalf("unexpected value: %d", conf.Uplink)
+	}
+	b, err := json.Marshal(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"Uplink":"1.5Gbps"}` {
+		t.Fatalf("unexpected JSON: %s", b)
+	}
+	var out struct{ Uplink Bandwidth }
+	if err := json.Unmarshal([]byte(`{"Uplink":"10Kbps"}`), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Uplink != 10e3 {
+		t.Fatalf("unexpected value: %d", out.Uplink)
+	}
+	if err := fs.Set("uplink", "fast"); err == nil {
+		t.Fatal("setting invalid rate should fail")
+	}
+}
+
+func TestSizeValue(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Adjust int64  `flag:"adjust,,bytesize"`
+		Limit  uint64 `flag:"limit,,bytesize"`
+	}{Limit: 1 << 20}
+	DefineFlagSet(fs, &conf)
+	if got := fs.Lookup("limit").DefValue; got != "1MiB" {
+		t.Fatalf("unexpected default: %q", got)
+	}
+	for _, tc := range []struct {
+		arg  string
+		want int64
+		str  string
+	}{
+		{"-10MiB", -10 << 20, "-10MiB"},
+		{"+2KB", 2000, "2KB"},
+		{"0", 0, "0B"},
+		{"-0", 0, "0B"},
+		{"1.5KiB", 1536, "1536B"},
+		{"-9223372036854775808", -1 << 63, "-8192PiB"},
+	} {
+		if err := fs.Set("adjust", tc.arg); err != nil {
+			t.Fatalf("setting %q: %v", tc.arg, err)
+		}
+		if conf.Adjust != tc.want {
+			t.Errorf("%q: got %d, want %d", tc.arg, conf.Adjust, tc.want)
+		}
+		if got := fs.Lookup("adjust").Value.String(); got != tc.str {
+			t.Errorf("%q: String() = %q, want %q", tc.arg, got, tc.str)
+		}
+	}
+	for _, arg := range []string{"-1KiB", "10XB", "--1"} {
+		if err := fs.Set("limit", arg); err == nil {
+			t.Errorf("setting %q should fail", arg)
+		}
+	}
+	if err := fs.Set("adjust", "9223372036854775808"); err == nil {
+		t.Error("setting out of range value should fail")
+	}
+}
@@ -0,0 +1,293 @@
+package autoflags
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ShowGoTypes controls whether [PrintDefaults] uses Go type names of struct
+// fields as placeholders for flags that flag package can only describe as
+// generic "value", like those of custom [flag.Value] types.
+var ShowGoTypes = false
+
+// PrintDefaults prints to fs output the default values of all defined flags
+// in the same format as [flag.FlagSet.PrintDefaults] does, taking into account
+// metadata of flags defined by this package. Hidden flags and aliases are not
+// listed. Defaults equal to the zero value of the flag type, like empty strings
+// or 0, are omitted unless set explicitly with default option, defaults of
+// flags with censor option are masked. Duration defaults are shown without
+// zero components, like 15m instead of 15m0s.
+func PrintDefaults(fs *flag.FlagSet) {
+	infos := flagInfoMap(fs)
+	fs.VisitAll(func(f *flag.Flag) {
+		if info := infos[f.Name]; !info.unlisted(f.Name) {
+			printFlag(fs.Output(), f, info, 0)
+		}
+	})
+}
+
+// PrintDefaultsWrapped works like [PrintDefaults], but prints to w and wraps
+// usage strings so that lines do not exceed width columns, assuming 8-column
+// tab stops. Words longer than the available space are not broken. Width 0
+// means no wrapping.
+func PrintDefaultsWrapped(fs *flag.FlagSet, w io.Writer, width int) {
+	infos := flagInfoMap(fs)
+	fs.VisitAll(func(f *flag.Flag) {
+		if info := infos[f.Name]; !info.unlisted(f.Name) {
+			printFlag(w, f, info, width)
+		}
+	})
+}
+
+// PrintGrouped works like [PrintDefaults], but lists flags under headings of
+// their groups, set either by group option or implicitly for flags of nested
+// structs. Flags without group are printed first.
+func PrintGrouped(fs *flag.FlagSet) {
+	infos := flagInfoMap(fs)
+	var groups []string
+	grouped := make(map[string][]*flag.Flag)
+	for _, info := range flagInfos(fs) {
+		if g := info.opts["group"]; g != "" {
+			if _, ok := grouped[g]; !ok {
+				groups = append(groups, g)
+				grouped[g] = nil
+			}
+		}
+	}
+	var ungrouped []*flag.Flag
+	fs.VisitAll(func(f *flag.Flag) {
+		info := infos[f.Name]
+		if info.unlisted(f.Name) {
+			return
+		}
+		if info != nil && info.opts["group"] != "" {
+			grouped[info.opts["group"]] = append(grouped[info.opts["group"]], f)
+			return
+		}
+		ungrouped = append(ungrouped, f)
+	})
+	w := fs.Output()
+	for _, f := range ungrouped {
+		printFlag(w, f, infos[f.Name], 0)
+	}
+	for i, g := range groups {
+		if i > 0 || len(ungrouped) != 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s:\n", g)
+		for _, f := range grouped[g] {
+			printFlag(w, f, infos[f.Name], 0)
+		}
+	}
+}
+
+// PrintByRequirement works like [PrintDefaults], but prints to w flags having
+// required option under "Required:" heading, followed by other flags under
+// "Options:" heading. Flags defined by this package are listed in order of
+// their definition, followed by other flags in lexicographical order;
+// sections without flags are omitted.
+func PrintByRequirement(fs *flag.FlagSet, w io.Writer) {
+	infos := flagInfoMap(fs)
+	var required, optional []*flag.Flag
+	for _, info := range flagInfos(fs) {
+		for _, name := range info.names() {
+			f := fs.Lookup(name)
+			if f == nil || info.unlisted(name) || infos[name] != info {
+				continue
+			}
+			if info.opts.has("required") {
+				required = append(required, f)
+			} else {
+				optional = append(optional, f)
+			}
+		}
+	}
+	fs.VisitAll(func(f *flag.Flag) {
+		if infos[f.Name] == nil {
+			optional = append(optional, f)
+		}
+	})
+	for i, section := range []struct {
+		title string
+		flags []*flag.Flag
+	}{{"Required", required}, {"Options", optional}} {
+		if len(section.flags) == 0 {
+			continue
+		}
+		if i > 0 && len(required) != 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s:\n", section.title)
+		for _, f := range section.flags {
+			printFlag(w, f, infos[f.Name], 0)
+		}
+	}
+}
+
+// printFlag prints flag usage the same way [flag.FlagSet.PrintDefaults] does,
+// wrapping usage to width columns if it is positive
+func printFlag(w io.Writer, f *flag.Flag, info *flagInfo, width int) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "  -%s", f.Name)
+	name, usage := unquoteUsage(f, info)
+	if len(name) > 0 {
+		b.WriteString(" ")
+		b.WriteString(name)
+	}
+	// Boolean flags of one ASCII letter are so common we
+	// treat them specially, putting their usage on the same line.
+	if b.Len() <= 4 { // space, space, '-', 'x'.
+		b.WriteString("\t")
+	} else {
+		// Four spaces before the tab triggers good alignment
+		// for both 4- and 8-space tab stops.
+		b.WriteString("\n    \t")
+	}
+	if !isZeroValue(f) || (info != nil && info.opts.has("default")) {
+		switch {
+		case info.censored():
+			usage += " (default " + censoredText + ")"
+		case isStringFlag(f):
+			usage += fmt.Sprintf(" (default %q)", f.DefValue)
+		default:
+			usage += fmt.Sprintf(" (default %v)", defaultText(f))
+		}
+	}
+	if width > 0 {
+		usage = wrapText(usage, width-usageIndent)
+	}
+	b.WriteString(strings.ReplaceAll(usage, "\n", "\n    \t"))
+	fmt.Fprint(w, b.String(), "\n")
+}
+
+// usageIndent is the column usage text starts at, given 8-column tab stops
+const usageIndent = 8
+
+// wrapText wraps lines of s at word boundaries so that they don't exceed
+// width, if possible
+func wrapText(s string, width int) string {
+	if width < 1 {
+		width = 1
+	}
+	lines := strings.Split(s, "\n")
+	var out []string
+	for _, line := range lines {
+		var cur string
+		for _, word := range strings.Fields(line) {
+			switch {
+			case cur == "":
+				cur = word
+			case len(cur)+1+len(word) <= width:
+				cur += " " + word
+			default:
+				out = append(out, cur)
+				cur = word
+			}
+		}
+		out = append(out, cur)
+	}
+	return strings.Join(out, "\n")
+}
+
+// Synopsis returns one-line summary of flags defined on fs, like
+//
+//	prog -name string [-age uint] [-v]
+//
+// Flags having required option are listed without brackets.
+func Synopsis(fs *flag.FlagSet, prog string) string {
+	infos := flagInfoMap(fs)
+	b := []string{prog}
+	fs.VisitAll(func(f *flag.Flag) {
+		info := infos[f.Name]
+		if info.unlisted(f.Name) {
+			return
+		}
+		s := "-" + f.Name
+		if name, _ := unquoteUsage(f, info); name != "" {
+			s += " " + name
+		}
+		if info == nil || !info.opts.has("required") {
+			s = "[" + s + "]"
+		}
+		b = append(b, s)
+	})
+	return strings.Join(b, " ")
+}
+
+// unquoteUsage works like [flag.UnquoteUsage], but if [ShowGoTypes] is set,
+// it uses Go type from info as a placeholder instead of generic "value".
+// Wrappers are removed from the flag value, so that placeholder is derived
+// from the type of the wrapped value.
+func unquoteUsage(f *flag.Flag, info *flagInfo) (name, usage string) {
+	uf := *f
+	uf.Value = unwrapValue(f.Value)
+	name, usage = flag.UnquoteUsage(&uf)
+	if info != nil && ShowGoTypes && name == "value" {
+		name = typeName(info.typ)
+	}
+	return name, usage
+}
+
+// typeName returns short name of the type suitable to be used as a flag
+// value placeholder
+func typeName(typ reflect.Type) string {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if name := typ.Name(); name != "" {
+		return name
+	}
+	return typ.String()
+}
+
+// isZeroValue reports whether f.DefValue is the zero value of the flag type,
+// it mimics unexported function of the flag package. Wrappers are removed
+// from the flag value, so the type of the wrapped value is used.
+func isZeroValue(f *flag.Flag) bool {
+	typ := reflect.TypeOf(unwrapValue(f.Value))
+	var z reflect.Value
+	if typ.Kind() == reflect.Ptr {
+		z = reflect.New(typ.Elem())
+	} else {
+		z = reflect.Zero(typ)
+	}
+	v, ok := z.Interface().(flag.Value)
+	if !ok {
+		return false
+	}
+	return f.DefValue == v.String()
+}
+
+// defaultText returns f default value as text, it trims zero components from
+// durations for readability, so that 15m0s is shown as 15m.
+func defaultText(f *flag.Flag) string {
+	g, ok := unwrapValue(f.Value).(flag.Getter)
+	if !ok {
+		return f.DefValue
+	}
+	if _, ok := g.Get().(time.Duration); !ok {
+		return f.DefValue
+	}
+	s := f.DefValue
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// isStringFlag reports whether f holds a string value, such defaults are
+// printed quoted.
+func isStringFlag(f *flag.Flag) bool {
+	if g, ok := unwrapValue(f.Value).(flag.Getter); ok {
+		_, ok := g.Get().(string)
+		return ok
+	}
+	return false
+}
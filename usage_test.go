@@ -0,0 +1,353 @@
+package autoflags
+
+import (
+	"bytes"
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrintDefaults(t *testing.T) {
+	newFlagSet := func() (*flag.FlagSet, *bytes.Buffer) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		buf := new(bytes.Buffer)
+		fs.SetOutput(buf)
+		conf := struct {
+			Name    string        `flag:"name,user name"`
+			Timeout time.Duration `flag:"timeout"`
+			V       bool          `flag:"v,verbose"`
+			Slice   CustomFlag    "flag:\"slice,list of `items`\""
+			Regions CustomFlag    `flag:"region"`
+		}{Name: "John Doe", Timeout: time.Second}
+		DefineFlagSet(fs, &conf)
+		return fs, buf
+	}
+	fs, buf := newFlagSet()
+	fs.PrintDefaults()
+	want := buf.String()
+	buf.Reset()
+	PrintDefaults(fs)
+	if got := buf.String(); got != want {
+		t.Fatalf("output differs from flag.PrintDefaults, got:\n%s\nwant:\n%s", got, want)
+	}
+
+	defer func(v bool) { ShowGoTypes = v }(ShowGoTypes)
+	ShowGoTypes = true
+	fs, buf = newFlagSet()
+	PrintDefaults(fs)
+	want = `  -name string
+    	user name (default "John Doe")
+  -region CustomFlag
+    	
+  -slice items
+    	list of items
+  -timeout duration
+    	 (default 1s)
+  -v	verbose
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestPrintDefaultsWrappedValues(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	var buf bytes.Buffer
+	fs.SetOutput(&buf)
+	conf := struct {
+		Name string   `flag:"name,,nonempty"`
+		Mode string   `flag:"mode,,oneof=text|json"`
+		Tags []string `flag:"tag,,nonempty"`
+	}{Name: "x"}
+	DefineFlagSet(fs, &conf)
+	PrintDefaults(fs)
+	want := `  -mode string
+    	one of: text, json
+  -name string
+    	 (default "x")
+  -tag value
+    	
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestPrintDefaultsStringOptions(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	var buf bytes.Buffer
+	fs.SetOutput(&buf)
+	conf := struct {
+		Password string `flag:"password,,prompt,once"`
+		Key      string `flag:"key,,fromfile"`
+		Greeting string `flag:"greeting,,template"`
+	}{Password: "-", Key: "key.pem", Greeting: "hi there"}
+	DefineFlagSet(fs, &conf)
+	PrintDefaults(fs)
+	for _, want := range []string{`(default "-")`, `(default "key.pem")`, `(default "hi there")`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output has no %s:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestPrintDefaultsExplicitDefault(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	buf := new(bytes.Buffer)
+	fs.SetOutput(buf)
+	conf := struct {
+		Retries int      `flag:"retries,retry count,default=0"`
+		Workers int      `flag:"workers,worker count,default=4"`
+		Name    string   `flag:"name,user name"`
+		Tags    []string `flag:"tag,tags,default=a|b,sep=|"`
+	}{}
+	DefineFlagSet(fs, &conf)
+	if conf.Workers != 4 {
+		t.Fatalf("default not applied: %d", conf.Workers)
+	}
+	if err := fs.Parse([]string{"-tag", "c"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"c"}; !reflect.DeepEqual(conf.Tags, want) {
+		t.Fatalf("got %q, want %q", conf.Tags, want)
+	}
+	PrintDefaults(fs)
+	want := `  -name string
+    	user name
+  -retries int
+    	retry count (default 0)
+  -tag value
+    	tags (default a|b)
+  -workers int
+    	worker count (default 4)
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	err := DefineFlagSetStrict(flag.NewFlagSet("test", flag.ContinueOnError), &struct {
+		N int `flag:"n,,default=x"`
+	}{})
+	if err == nil || !strings.Contains(err.Error(), `invalid default "x"`) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestPrintDefaultsWrapped(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	DefineFlagSet(fs, &struct {
+		Addr string `flag:"addr,address to listen on for incoming connections, either host:port or unix socket path"`
+		V    bool   `flag:"v,verbose output"`
+	}{Addr: "localhost:8080"})
+	var buf bytes.Buffer
+	PrintDefaultsWrapped(fs, &buf, 40)
+	want := `  -addr string
+    	address to listen on for
+    	incoming connections, either
+    	host:port or unix socket path
+    	(default "localhost:8080")
+  -v	verbose output
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	buf.Reset()
+	PrintDefaultsWrapped(fs, &buf, 0)
+	if got := buf.String(); strings.Count(got, "\n") != 3 {
+		t.Fatalf("width 0 should disable wrapping, got:\n%s", got)
+	}
+}
+
+func TestPrintDefaultsDuration(t *testing.T) {
+	for _, tc := range []struct {
+		d    time.Duration
+		want string
+	}{
+		{15 * time.Minute, "15m"},
+		{time.Hour, "1h"},
+		{90 * time.Minute, "1h30m"},
+		{time.Hour + time.Second, "1h0m1s"},
+		{10 * time.Second, "10s"},
+		{1500 * time.Millisecond, "1.5s"},
+	} {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		var buf bytes.Buffer
+		fs.SetOutput(&buf)
+		DefineFlagSet(fs, &struct {
+			Timeout time.Duration `flag:"timeout"`
+		}{tc.d})
+		PrintDefaults(fs)
+		if want := "  -timeout duration\n    \t (default " + tc.want + ")\n"; buf.String() != want {
+			t.Errorf("%v: got %q, want %q", tc.d, buf.String(), want)
+		}
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	var buf bytes.Buffer
+	fs.SetOutput(&buf)
+	DefineFlagSet(fs, &struct {
+		Timeout time.Duration `flag:"timeout,,defaultunit=s"`
+	}{15 * time.Minute})
+	PrintDefaults(fs)
+	if want := "  -timeout duration\n    \t (default 15m)\n"; buf.String() != want {
+		t.Errorf("defaultunit: got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestUsageFunc(t *testing.T) {
+	UsageFunc = func(name, usage string, info FlagInfo) string {
+		if info.Group != "" {
+			usage = "[" + info.Group + "] " + usage
+		}
+		if info.Env != "" {
+			usage += " (env " + info.Env + ")"
+		}
+		return usage
+	}
+	defer func() { UsageFunc = nil }()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	DefineFlagSet(fs, &struct {
+		Addr string `flag:"addr,listen address,env=ADDR"`
+		DB   struct {
+			Host string `flag:"db-host,database host"`
+		}
+	}{})
+	for name, want := range map[string]string{
+		"addr":    "listen address (env ADDR)",
+		"db-host": "[DB] database host",
+	} {
+		if got := fs.Lookup(name).Usage; got != want {
+			t.Errorf("-%s usage is %q, want %q", name, got, want)
+		}
+	}
+	if got := Flags(fs)[0].Usage; got != "listen address (env ADDR)" {
+		t.Errorf("metadata has usage %q", got)
+	}
+}
+
+func TestSynopsis(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Name string `flag:"name,,required"`
+		Age  uint   `flag:"age"`
+		V    bool   `flag:"v"`
+	}{}
+	DefineFlagSet(fs, &conf)
+	want := "prog [-age uint] -name string [-v]"
+	if got := Synopsis(fs, "prog"); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestPrintDefaultsFlagValue(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	buf := new(bytes.Buffer)
+	fs.SetOutput(buf)
+	conf := struct {
+		Slice CustomFlag `flag:"slice,list of items"`
+	}{Slice: CustomFlag{"a", "b"}}
+	DefineFlagSet(fs, &conf)
+	if err := fs.Parse([]string{"-slice", "c"}); err != nil {
+		t.Fatal(err)
+	}
+	PrintDefaults(fs)
+	want := "  -slice value\n    \tlist of items (default [a b])\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("got:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestPrintByRequirement(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Verbose bool   `flag:"v,verbose"`
+		Name    string `flag:"name,user name,required"`
+		Secret  string `flag:"secret,,hidden"`
+		Addr    string `flag:"addr,address,required,short=a"`
+	}{}
+	DefineFlagSet(fs, &conf)
+	fs.Int("extra", 0, "not defined by autoflags")
+	buf := new(bytes.Buffer)
+	PrintByRequirement(fs, buf)
+	want := `Required:
+  -name string
+    	user name
+  -addr string
+    	address
+  -a string
+    	address
+
+Options:
+  -v	verbose
+  -extra int
+    	not defined by autoflags
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestPrintGrouped(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	buf := new(bytes.Buffer)
+	fs.SetOutput(buf)
+	type tlsConfig struct {
+		Cert string `flag:"cert,certificate file"`
+		Key  string `flag:"key,key file"`
+	}
+	conf := struct {
+		Verbose bool `flag:"v,verbose"`
+		HTTP    struct {
+			Addr  string `flag:"addr,address to listen"`
+			Debug bool   `flag:"debug,enable debug,group=Debugging"`
+		}
+		TLS *tlsConfig
+	}{TLS: &tlsConfig{}}
+	DefineFlagSet(fs, &conf)
+	PrintGrouped(fs)
+	want := `  -v	verbose
+
+HTTP:
+  -addr string
+    	address to listen
+
+Debugging:
+  -debug
+    	enable debug
+
+TLS:
+  -cert string
+    	certificate file
+  -key string
+    	key file
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	infos := Flags(fs)
+	if len(infos) != 5 || infos[3].Field != "TLS.Cert" || infos[3].Group != "TLS" {
+		t.Fatalf("unexpected metadata: %+v", infos)
+	}
+}
+
+func TestHiddenFlag(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	buf := new(bytes.Buffer)
+	fs.SetOutput(buf)
+	conf := struct {
+		Name  string `flag:"name,user name"`
+		Shard int    `flag:"shard,shard number for power users,hidden"`
+	}{}
+	DefineFlagSet(fs, &conf)
+	PrintDefaults(fs)
+	if want := "  -name string\n    \tuser name\n"; buf.String() != want {
+		t.Fatalf("got:\n%q\nwant:\n%q", buf, want)
+	}
+	err := fs.Set("shard", "x")
+	if err == nil || !strings.Contains(err.Error(), "shard number for power users") {
+		t.Fatalf("error should include usage, got %v", err)
+	}
+	if err := fs.Parse([]string{"-shard", "2"}); err != nil || conf.Shard != 2 {
+		t.Fatalf("unexpected result: %v, %v", conf.Shard, err)
+	}
+}
@@ -0,0 +1,146 @@
+package autoflags
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValidateTags is supposed to be called after flags are parsed, it checks
+// values of flag-tagged fields of config, including fields of nested structs,
+// against constraints listed in their `validate` tags:
+//
+//	Port int    `flag:"port" validate:"min=1,max=65535"`
+//	Mode string `flag:"mode" validate:"oneof=fast|safe"`
+//
+// Tag is a comma-separated list of the following rules:
+//
+//	min=n		number must not be less than n; for strings, slices
+//			and maps their length must not be less than n;
+//			time.Duration fields take durations like 1s
+//	max=n		same as min, but value must not exceed n
+//	nonempty	string must not be empty or consist of white space
+//			only, slice or map must have elements
+//	oneof=a|b|c	value, as formatted by fmt, must be one of the listed ones
+//
+// The first violated constraint is reported as an error, as well as
+// malformed validate tags. ValidateTags does not modify config, defaults set
+// by options like default or defaultfrom are not applied again.
+func ValidateTags(config interface{}) error {
+	st, err := structValue(config)
+	if err != nil {
+		return err
+	}
+	return walkTagged(st, defaultDefiner.tagKey(), func(val reflect.Value, sf reflect.StructField, spec tagSpec) error {
+		expr := sf.Tag.Get("validate")
+		if expr == "" {
+			return nil
+		}
+		for _, rule := range strings.Split(expr, ",") {
+			if err := validateRule(val, rule); err != nil {
+				return fmt.Errorf("autoflags: flag -%s: %w", spec.name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// validateRule checks v against a single rule of validate tag
+func validateRule(v reflect.Value, rule string) error {
+	name, arg := rule, ""
+	if i := strings.IndexByte(rule, '='); i >= 0 {
+		name, arg = rule[:i], rule[i+1:]
+	}
+	switch name {
+	case "min", "max":
+		c, err := compareBound(v, arg)
+		if err != nil {
+			return fmt.Errorf("invalid %s rule: %w", name, err)
+		}
+		what := "value " + formatElem(v)
+		switch v.Kind() {
+		case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+			what = "length " + strconv.Itoa(v.Len())
+		}
+		if name == "min" && c < 0 {
+			return fmt.Errorf("%s is less than %s", what, arg)
+		}
+		if name == "max" && c > 0 {
+			return fmt.Errorf("%s is greater than %s", what, arg)
+		}
+		return nil
+	case "nonempty":
+		switch v.Kind() {
+		case reflect.String:
+			if strings.TrimSpace(v.String()) == "" {
+				return fmt.Errorf("value must not be empty")
+			}
+		case reflect.Slice, reflect.Map:
+			if v.Len() == 0 {
+				return fmt.Errorf("value must not be empty")
+			}
+		default:
+			return fmt.Errorf("nonempty rule requires string, slice or map field")
+		}
+		return nil
+	case "oneof":
+		s := formatElem(v)
+		for _, choice := range strings.Split(arg, "|") {
+			if s == choice {
+				return nil
+			}
+		}
+		return fmt.Errorf("value %q is not one of: %s", s, strings.Join(strings.Split(arg, "|"), ", "))
+	}
+	return fmt.Errorf("unknown validate rule %q", rule)
+}
+
+// compareBound compares v, or its length for strings, slices and maps, with
+// bound, returning -1, 0 or 1 if v is less, equal or greater than bound
+func compareBound(v reflect.Value, bound string) (int, error) {
+	cmp := func(less, greater bool) int {
+		switch {
+		case less:
+			return -1
+		case greater:
+			return 1
+		}
+		return 0
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(bound)
+		if err != nil {
+			return 0, err
+		}
+		return cmp(time.Duration(v.Int()) < d, time.Duration(v.Int()) > d), nil
+	}
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		n, err := strconv.Atoi(bound)
+		if err != nil {
+			return 0, err
+		}
+		return cmp(v.Len() < n, v.Len() > n), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(bound, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		return cmp(v.Int() < n, v.Int() > n), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(bound, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		return cmp(v.Uint() < n, v.Uint() > n), nil
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(bound, 64)
+		if err != nil {
+			return 0, err
+		}
+		return cmp(v.Float() < f, v.Float() > f), nil
+	}
+	return 0, fmt.Errorf("unsupported field type %s", v.Type())
+}
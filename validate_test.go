@@ -0,0 +1,72 @@
+package autoflags
+
+import (
+	"flag"
+	"testing"
+	"time"
+)
+
+func TestValidateTags(t *testing.T) {
+	type conf struct {
+		Port    int           `flag:"port" validate:"min=1,max=65535"`
+		Mode    string        `flag:"mode" validate:"oneof=fast|safe"`
+		Name    string        `flag:"name" validate:"nonempty,max=8"`
+		Timeout time.Duration `flag:"timeout" validate:"min=1s"`
+		Nested  struct {
+			Tags []string `flag:"tag" validate:"min=1"`
+		}
+	}
+	valid := func() conf {
+		var c conf
+		c.Port, c.Mode, c.Name, c.Timeout = 80, "fast", "app", time.Minute
+		c.Nested.Tags = []string{"a"}
+		return c
+	}
+	c := valid()
+	if err := ValidateTags(&c); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		modify func(*conf)
+		want   string
+	}{
+		{func(c *conf) { c.Port = 0 }, "autoflags: flag -port: value 0 is less than 1"},
+		{func(c *conf) { c.Port = 70000 }, "autoflags: flag -port: value 70000 is greater than 65535"},
+		{func(c *conf) { c.Mode = "slow" }, `autoflags: flag -mode: value "slow" is not one of: fast, safe`},
+		{func(c *conf) { c.Name = " " }, "autoflags: flag -name: value must not be empty"},
+		{func(c *conf) { c.Name = "very long name" }, "autoflags: flag -name: length 14 is greater than 8"},
+		{func(c *conf) { c.Timeout = time.Millisecond }, "autoflags: flag -timeout: value 1ms is less than 1s"},
+		{func(c *conf) { c.Nested.Tags = nil }, "autoflags: flag -tag: length 0 is less than 1"},
+	} {
+		c := valid()
+		tc.modify(&c)
+		if err := ValidateTags(&c); err == nil || err.Error() != tc.want {
+			t.Errorf("got error %v, want %q", err, tc.want)
+		}
+	}
+	if err := ValidateTags(&struct {
+		Port int `flag:"port" validate:"min=one"`
+	}{}); err == nil {
+		t.Fatal("malformed rule should fail")
+	}
+}
+
+func TestValidateTagsUnmodified(t *testing.T) {
+	type conf struct {
+		Count int    `flag:"count,,default=5" validate:"max=10"`
+		Dir   string `flag:"dir"`
+		Back  string `flag:"back,,defaultfrom=Dir+/b"`
+	}
+	c := conf{Dir: "/d"}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	DefineFlagSet(fs, &c)
+	if err := fs.Parse([]string{"-count", "0", "-back", ""}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateTags(&c); err != nil {
+		t.Fatal(err)
+	}
+	if want := (conf{Dir: "/d"}); c != want {
+		t.Fatalf("config modified: got %+v, want %+v", c, want)
+	}
+}
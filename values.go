@@ -0,0 +1,1107 @@
+package autoflags
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+)
+
+// fileValue is a flag.Value treating its argument as a file name and storing
+// contents of that file, used for fields with fromfile option
+type fileValue struct{ p *string }
+
+func (v *fileValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	return *v.p
+}
+
+func (v *fileValue) Set(name string) error {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	s := strings.TrimSuffix(string(b), "\n")
+	*v.p = strings.TrimSuffix(s, "\r")
+	return nil
+}
+
+func (v *fileValue) Get() interface{} { return *v.p }
+
+// bytesTextValue is a flag.Value for []byte fields with text option, it
+// decodes Go escape sequences like \t in its argument and shows value as a
+// quoted string
+type bytesTextValue struct{ p *[]byte }
+
+func (v *bytesTextValue) String() string {
+	if v.p == nil {
+		return strconv.Quote("")
+	}
+	return strconv.Quote(string(*v.p))
+}
+
+func (v *bytesTextValue) Set(s string) error {
+	var b []byte
+	for rest := s; rest != ""; {
+		if rest[0] == '"' {
+			// quotes need no escaping, unlike in Go string literals
+			b, rest = append(b, '"'), rest[1:]
+			continue
+		}
+		r, multibyte, tail, err := strconv.UnquoteChar(rest, '"')
+		if err != nil {
+			return fmt.Errorf("invalid escape sequence in %q", s)
+		}
+		if r < utf8.RuneSelf || !multibyte {
+			b = append(b, byte(r))
+		} else {
+			var buf [utf8.UTFMax]byte
+			b = append(b, buf[:utf8.EncodeRune(buf[:], r)]...)
+		}
+		rest = tail
+	}
+	*v.p = b
+	return nil
+}
+
+func (v *bytesTextValue) Get() interface{} { return *v.p }
+
+// templateValue is a flag.Value for string fields with template option, it
+// treats its argument as a text/template and stores the result of its
+// execution against environment variables
+type templateValue struct{ p *string }
+
+func (v *templateValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	return *v.p
+}
+
+func (v *templateValue) Set(s string) error {
+	t, err := template.New("").Option("missingkey=error").Parse(s)
+	if err != nil {
+		return err
+	}
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if i := strings.IndexByte(kv, '='); i > 0 {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, env); err != nil {
+		return err
+	}
+	*v.p = b.String()
+	return nil
+}
+
+func (v *templateValue) Get() interface{} { return *v.p }
+
+// locationValue is a flag.Value for *time.Location fields
+type locationValue struct {
+	p        **time.Location
+	emptyUTC bool // whether empty argument means UTC
+}
+
+func (v *locationValue) String() string {
+	if v.p == nil || *v.p == nil {
+		return ""
+	}
+	return (*v.p).String()
+}
+
+func (v *locationValue) Set(s string) error {
+	if s == "" {
+		if !v.emptyUTC {
+			return errors.New("empty location name")
+		}
+		*v.p = time.UTC
+		return nil
+	}
+	loc, err := time.LoadLocation(s)
+	if err != nil {
+		return err
+	}
+	*v.p = loc
+	return nil
+}
+
+// unitDurationValue wraps flag.Value of time.Duration field, so that plain
+// integer arguments are interpreted in the given unit
+type unitDurationValue struct {
+	v    flag.Value
+	unit time.Duration
+}
+
+func (u *unitDurationValue) String() string {
+	if u.v == nil {
+		return "0s"
+	}
+	return u.v.String()
+}
+
+func (u *unitDurationValue) Get() interface{} {
+	if g, ok := u.v.(flag.Getter); ok {
+		return g.Get()
+	}
+	return nil
+}
+
+func (u *unitDurationValue) wrapped() flag.Value { return u.v }
+
+func (u *unitDurationValue) Set(s string) error {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		d := time.Duration(n) * u.unit
+		if d/u.unit != time.Duration(n) {
+			return fmt.Errorf("duration %q is out of range", s)
+		}
+		return u.v.Set(d.String())
+	}
+	return u.v.Set(s)
+}
+
+// textValue is a flag.Value for fields implementing encoding.TextUnmarshaler
+type textValue struct{ v reflect.Value }
+
+func (t *textValue) String() string {
+	if !t.v.IsValid() {
+		return ""
+	}
+	if m, ok := t.v.Addr().Interface().(encoding.TextMarshaler); ok {
+		b, err := m.MarshalText()
+		if err == nil {
+			return string(b)
+		}
+	}
+	return fmt.Sprintf("%v", t.v.Interface())
+}
+
+func (t *textValue) Set(s string) error {
+	return t.v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+}
+
+// compositeValue returns flag.Value for field v of a type not directly
+// supported by the flag package, or nil if field type is unsupported. If check
+// is not nil, it is called for each element before it is parsed.
+func compositeValue(v reflect.Value, opts tagOptions, check func(string) error) flag.Value {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+	default:
+		return nil
+	}
+	elem := v.Type().Elem()
+	// values of map[string][]T fields accumulate repeated keys
+	multi := v.Kind() == reflect.Map && elem.Kind() == reflect.Slice
+	if multi {
+		elem = elem.Elem()
+	}
+	parse := elemParser(elem)
+	if parse == nil {
+		return nil
+	}
+	if check != nil {
+		parseElem := parse
+		parse = func(s string) (reflect.Value, error) {
+			if err := check(s); err != nil {
+				return reflect.Value{}, err
+			}
+			return parseElem(s)
+		}
+	}
+	switch v.Kind() {
+	case reflect.Slice:
+		return newSliceValue(v, parse, opts)
+	case reflect.Array:
+		return newArrayValue(v, parse, opts)
+	case reflect.Map:
+		if v.Type().Key().Kind() == reflect.String {
+			mv := &mapValue{m: v, parse: parse, sep: "=", multi: multi, pairs: opts.has("pairs")}
+			if multi {
+				mv.sep = ":"
+			}
+			if sep, ok := opts["kvsep"]; ok {
+				mv.sep = sep
+			}
+			return mv
+		}
+	}
+	return nil
+}
+
+// elemCheck returns function validating argument, or its individual elements
+// of type typ for composite types, according to opts. It returns nil if no
+// validation is needed.
+func elemCheck(opts tagOptions, typ reflect.Type) (func(string) error, error) {
+	var checks []func(string) error
+	if choices, ok := opts.list("oneof"); ok && typ.Kind() == reflect.Float64 {
+		check, err := floatChoiceCheck(choices, opts["epsilon"])
+		if err != nil {
+			return nil, err
+		}
+		checks = append(checks, check)
+	} else if ok {
+		checks = append(checks, func(s string) error {
+			for _, c := range choices {
+				if s == c {
+					return nil
+				}
+			}
+			return fmt.Errorf("%q is not one of: %s", s, strings.Join(choices, ", "))
+		})
+	}
+	if opts.has("nonempty") {
+		checks = append(checks, func(s string) error {
+			if strings.TrimSpace(s) == "" {
+				return errors.New("value must not be blank")
+			}
+			return nil
+		})
+	}
+	if expr, ok := opts["pattern"]; ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern: %w", err)
+		}
+		checks = append(checks, func(s string) error {
+			if !re.MatchString(s) {
+				return fmt.Errorf("%q does not match pattern %s", s, expr)
+			}
+			return nil
+		})
+	}
+	if len(checks) == 0 {
+		return nil, nil
+	}
+	return func(s string) error {
+		for _, check := range checks {
+			if err := check(s); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
+
+// floatChoiceCheck returns function checking that argument is a number equal
+// to one of choices within tolerance given as epsilon, 1e-9 if empty
+func floatChoiceCheck(choices []string, epsilon string) (func(string) error, error) {
+	eps := 1e-9
+	if epsilon != "" {
+		var err error
+		if eps, err = strconv.ParseFloat(epsilon, 64); err != nil || eps < 0 {
+			return nil, fmt.Errorf("invalid epsilon %q", epsilon)
+		}
+	}
+	allowed := make([]float64, len(choices))
+	for i, c := range choices {
+		f, err := strconv.ParseFloat(c, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid oneof value %q", c)
+		}
+		allowed[i] = f
+	}
+	return func(s string) error {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		for _, a := range allowed {
+			if math.Abs(f-a) <= eps {
+				return nil
+			}
+		}
+		return fmt.Errorf("%v is not one of: %s", f, strings.Join(choices, ", "))
+	}, nil
+}
+
+// wrapper is embedded into flag.Value wrappers, it forwards methods other
+// than Set to the wrapped value
+type wrapper struct{ v flag.Value }
+
+func (w wrapper) String() string {
+	if w.v == nil {
+		return ""
+	}
+	return w.v.String()
+}
+
+func (w wrapper) Get() interface{} {
+	if g, ok := w.v.(flag.Getter); ok {
+		return g.Get()
+	}
+	return w.String()
+}
+
+// wrapped returns the wrapped value
+func (w wrapper) wrapped() flag.Value { return w.v }
+
+// unwrapValue returns v with all wrappers removed
+func unwrapValue(v flag.Value) flag.Value {
+	for {
+		w, ok := v.(interface{ wrapped() flag.Value })
+		if !ok {
+			return v
+		}
+		v = w.wrapped()
+	}
+}
+
+// markDefault tells v and values it wraps that their current value is
+// a default, so that Set calls made so far are not taken into account
+func markDefault(v flag.Value) {
+	for v != nil {
+		if d, ok := v.(interface{ markDefault() }); ok {
+			d.markDefault()
+		}
+		w, ok := v.(interface{ wrapped() flag.Value })
+		if !ok {
+			return
+		}
+		v = w.wrapped()
+	}
+}
+
+func (w wrapper) IsBoolFlag() bool {
+	b, ok := w.v.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// humanBoolValue wraps flag.Value of bool field with humanbool option, so that
+// it accepts the arguments understood by parseBool
+type humanBoolValue struct{ wrapper }
+
+func (h *humanBoolValue) String() string {
+	if h.v == nil {
+		return "false"
+	}
+	return h.v.String()
+}
+
+func (h *humanBoolValue) Set(s string) error {
+	b, err := parseBool(s)
+	if err != nil {
+		return err
+	}
+	return h.v.Set(strconv.FormatBool(b))
+}
+
+// invertedValue is a boolean flag.Value for bool fields with invert option,
+// it stores the negation of its argument in the field
+type invertedValue struct{ p *bool }
+
+func (v *invertedValue) String() string {
+	if v.p == nil {
+		return "false"
+	}
+	return strconv.FormatBool(!*v.p)
+}
+
+func (v *invertedValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	*v.p = !b
+	return nil
+}
+
+func (v *invertedValue) Get() interface{} { return !*v.p }
+func (v *invertedValue) IsBoolFlag() bool { return true }
+
+// namedLevelValue is a flag.Value for signed integer fields with levels
+// option, it takes either a level name or a number.
+type namedLevelValue struct {
+	v      reflect.Value
+	names  []string
+	levels []int64
+}
+
+// newNamedLevelValue returns namedLevelValue for field v, spec is the levels
+// option value of "name:value|name:value" form
+func newNamedLevelValue(v reflect.Value, spec string) (*namedLevelValue, error) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+	default:
+		return nil, errors.New("levels option requires signed integer field")
+	}
+	lv := &namedLevelValue{v: v}
+	for _, pair := range strings.Split(spec, "|") {
+		i := strings.IndexByte(pair, ':')
+		if i <= 0 {
+			return nil, fmt.Errorf("levels option: name:value expected, got %q", pair)
+		}
+		n, err := strconv.ParseInt(pair[i+1:], 0, v.Type().Bits())
+		if err != nil {
+			return nil, fmt.Errorf("levels option: invalid value of %q", pair[:i])
+		}
+		lv.names = append(lv.names, pair[:i])
+		lv.levels = append(lv.levels, n)
+	}
+	return lv, nil
+}
+
+func (l *namedLevelValue) String() string {
+	if !l.v.IsValid() {
+		return ""
+	}
+	n := l.v.Int()
+	for i, level := range l.levels {
+		if level == n {
+			return l.names[i]
+		}
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+func (l *namedLevelValue) Set(s string) error {
+	for i, name := range l.names {
+		if strings.EqualFold(name, s) {
+			l.v.SetInt(l.levels[i])
+			return nil
+		}
+	}
+	n, err := strconv.ParseInt(s, 0, l.v.Type().Bits())
+	if err != nil {
+		return fmt.Errorf("unknown level %q, valid ones are: %s", s, strings.Join(l.names, ", "))
+	}
+	l.v.SetInt(n)
+	return nil
+}
+
+func (l *namedLevelValue) Get() interface{} { return l.v.Interface() }
+
+// bitsValue is a flag.Value for unsigned integer fields with bits option, it
+// takes comma-separated lists of bit names and stores them ORed together; the
+// first call to Set replaces the default value.
+type bitsValue struct {
+	v     reflect.Value
+	names []string
+	bits  []uint64
+	set   bool // whether Set was called
+}
+
+// newBitsValue returns bitsValue for field v, spec is the bits option value
+// of "name:value|name:value" form
+func newBitsValue(v reflect.Value, spec string) (*bitsValue, error) {
+	switch v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	default:
+		return nil, errors.New("bits option requires unsigned integer field")
+	}
+	bv := &bitsValue{v: v}
+	for _, pair := range strings.Split(spec, "|") {
+		i := strings.IndexByte(pair, ':')
+		if i <= 0 {
+			return nil, fmt.Errorf("bits option: name:value expected, got %q", pair)
+		}
+		n, err := strconv.ParseUint(pair[i+1:], 0, v.Type().Bits())
+		if err != nil || n == 0 {
+			return nil, fmt.Errorf("bits option: invalid value of %q", pair[:i])
+		}
+		bv.names = append(bv.names, pair[:i])
+		bv.bits = append(bv.bits, n)
+	}
+	return bv, nil
+}
+
+func (b *bitsValue) String() string {
+	if !b.v.IsValid() {
+		return ""
+	}
+	n := b.v.Uint()
+	var names []string
+	for i, bit := range b.bits {
+		if n&bit == bit {
+			names = append(names, b.names[i])
+			n &^= bit
+		}
+	}
+	if n != 0 {
+		names = append(names, strconv.FormatUint(n, 10))
+	}
+	return strings.Join(names, ",")
+}
+
+func (b *bitsValue) markDefault() { b.set = false }
+
+func (b *bitsValue) Set(s string) error {
+	var n uint64
+	if b.set {
+		n = b.v.Uint()
+	}
+	if s != "" {
+	tokens:
+		for _, name := range strings.Split(s, ",") {
+			name = strings.TrimSpace(name)
+			for i := range b.names {
+				if b.names[i] == name {
+					n |= b.bits[i]
+					continue tokens
+				}
+			}
+			if bit, err := strconv.ParseUint(name, 10, b.v.Type().Bits()); err == nil {
+				n |= bit
+				continue
+			}
+			return fmt.Errorf("unknown name %q, valid ones are: %s", name, strings.Join(b.names, ", "))
+		}
+	}
+	b.v.SetUint(n)
+	b.set = true
+	return nil
+}
+
+// parseBool works like strconv.ParseBool, but also accepts yes, no, on and
+// off in any case
+func parseBool(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "yes", "on":
+		return true, nil
+	case "no", "off":
+		return false, nil
+	}
+	b, err := strconv.ParseBool(strings.ToLower(s))
+	if err != nil {
+		return false, fmt.Errorf("invalid boolean value %q", s)
+	}
+	return b, nil
+}
+
+// checkedValue wraps flag.Value validating arguments before they're passed to
+// the wrapped Set method
+type checkedValue struct {
+	wrapper
+	check func(string) error
+}
+
+func (c *checkedValue) Set(s string) error {
+	if err := c.check(s); err != nil {
+		return err
+	}
+	return c.v.Set(s)
+}
+
+// hiddenValue wraps flag.Value of a hidden flag, adding flag usage to errors
+// since it is not listed in the help output
+type hiddenValue struct {
+	wrapper
+	usage string
+}
+
+func (h *hiddenValue) Set(s string) error {
+	if err := h.v.Set(s); err != nil {
+		return fmt.Errorf("%w (flag usage: %s)", err, h.usage)
+	}
+	return nil
+}
+
+// rawJSONValue is a flag.Value for json.RawMessage fields, it stores argument
+// as is once it is validated to be well-formed JSON
+type rawJSONValue struct{ p *json.RawMessage }
+
+func (v *rawJSONValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	return string(*v.p)
+}
+
+func (v *rawJSONValue) Set(s string) error {
+	if !json.Valid([]byte(s)) {
+		return errors.New("malformed JSON")
+	}
+	*v.p = json.RawMessage(s)
+	return nil
+}
+
+func (v *rawJSONValue) Get() interface{} { return *v.p }
+
+// onceValue wraps flag.Value of a flag with once option, it fails if Set is
+// called more than once
+type onceValue struct {
+	wrapper
+	name string // flag name used in errors
+	set  bool   // whether Set was called
+}
+
+func (o *onceValue) Set(s string) error {
+	if o.set {
+		return fmt.Errorf("flag -%s can only be set once", o.name)
+	}
+	if err := o.v.Set(s); err != nil {
+		return err
+	}
+	o.set = true
+	return nil
+}
+
+func (o *onceValue) markDefault() { o.set = false }
+
+// historyValue wraps flag.Value of a flag with history option, recording
+// arguments of successful Set calls
+type historyValue struct {
+	wrapper
+	history []string
+}
+
+func (h *historyValue) Set(s string) error {
+	if err := h.v.Set(s); err != nil {
+		return err
+	}
+	h.history = append(h.history, s)
+	return nil
+}
+
+// elemParser returns function to parse string into value of type typ, or nil
+// if such type is not supported as a map or slice element.
+func elemParser(typ reflect.Type) func(string) (reflect.Value, error) {
+	switch typ {
+	case durationType:
+		return func(s string) (reflect.Value, error) {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			return reflect.ValueOf(d), nil
+		}
+	case ipNetType:
+		return func(s string) (reflect.Value, error) {
+			_, n, err := net.ParseCIDR(s)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			return reflect.ValueOf(*n), nil
+		}
+	case hardwareAddrType:
+		return func(s string) (reflect.Value, error) {
+			addr, err := net.ParseMAC(s)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			return reflect.ValueOf(addr), nil
+		}
+	}
+	switch typ {
+	case weekdayType:
+		return calendarParser(typ, 0, 6, func(n int) string { return time.Weekday(n).String() })
+	case monthType:
+		return calendarParser(typ, 1, 12, func(n int) string { return time.Month(n).String() })
+	}
+	if isPairType(typ) {
+		return func(s string) (reflect.Value, error) {
+			i := strings.IndexByte(s, '=')
+			if i < 0 {
+				return reflect.Value{}, errors.New("key=value form expected")
+			}
+			v := reflect.New(typ).Elem()
+			v.FieldByName("Key").SetString(s[:i])
+			v.FieldByName("Value").SetString(s[i+1:])
+			return v, nil
+		}
+	}
+	switch typ.Kind() {
+	case reflect.String:
+		return func(s string) (reflect.Value, error) {
+			return reflect.ValueOf(s).Convert(typ), nil
+		}
+	case reflect.Bool:
+		return func(s string) (reflect.Value, error) {
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			return reflect.ValueOf(b).Convert(typ), nil
+		}
+	case reflect.Int:
+		return func(s string) (reflect.Value, error) {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			return reflect.ValueOf(n).Convert(typ), nil
+		}
+	case reflect.Uint, reflect.Uint64:
+		return func(s string) (reflect.Value, error) {
+			n, err := strconv.ParseUint(s, 10, typ.Bits())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			return reflect.ValueOf(n).Convert(typ), nil
+		}
+	case reflect.Float64:
+		return func(s string) (reflect.Value, error) {
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			return reflect.ValueOf(f).Convert(typ), nil
+		}
+	}
+	return nil
+}
+
+var (
+	ipNetType        = reflect.TypeOf(net.IPNet{})
+	hardwareAddrType = reflect.TypeOf(net.HardwareAddr(nil))
+	durationType     = reflect.TypeOf(time.Duration(0))
+	weekdayType      = reflect.TypeOf(time.Weekday(0))
+	monthType        = reflect.TypeOf(time.Month(0))
+)
+
+// calendarParser returns function parsing values of time.Weekday or
+// time.Month type typ, given either as a number from min to max, or as an
+// English name, full or abbreviated to three letters, in any case
+func calendarParser(typ reflect.Type, min, max int, name func(int) string) func(string) (reflect.Value, error) {
+	return func(s string) (reflect.Value, error) {
+		if n, err := strconv.Atoi(s); err == nil {
+			if n < min || n > max {
+				return reflect.Value{}, fmt.Errorf("%s value %d is out of range %d..%d", typ, n, min, max)
+			}
+			return reflect.ValueOf(n).Convert(typ), nil
+		}
+		var names []string
+		for n := min; n <= max; n++ {
+			full := name(n)
+			if strings.EqualFold(s, full) || strings.EqualFold(s, full[:3]) {
+				return reflect.ValueOf(n).Convert(typ), nil
+			}
+			names = append(names, full)
+		}
+		return reflect.Value{}, fmt.Errorf("unknown %s %q, valid ones are: %s", typ, s, strings.Join(names, ", "))
+	}
+}
+
+// isPairType reports whether typ is a struct of two string fields named Key
+// and Value, like struct{ Key, Value string }
+func isPairType(typ reflect.Type) bool {
+	if typ.Kind() != reflect.Struct || typ.NumField() != 2 {
+		return false
+	}
+	for _, name := range []string{"Key", "Value"} {
+		f, ok := typ.FieldByName(name)
+		if !ok || f.PkgPath != "" || f.Type.Kind() != reflect.String {
+			return false
+		}
+	}
+	return true
+}
+
+// formatElem returns string representation of v, taking into account String
+// methods with pointer receivers
+func formatElem(v reflect.Value) string {
+	if isPairType(v.Type()) {
+		return v.FieldByName("Key").String() + "=" + v.FieldByName("Value").String()
+	}
+	if !v.CanAddr() {
+		cp := reflect.New(v.Type()).Elem()
+		cp.Set(v)
+		v = cp
+	}
+	if s, ok := v.Addr().Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// elemValue is a flag.Value for scalar fields of types handled by elemParser
+type elemValue struct {
+	v     reflect.Value
+	parse func(string) (reflect.Value, error)
+}
+
+func (e *elemValue) String() string {
+	// zero time.Weekday is Sunday, a valid value unlike zero of other types
+	if !e.v.IsValid() || e.v.IsZero() && e.v.Type() != weekdayType {
+		return ""
+	}
+	return formatElem(e.v)
+}
+
+func (e *elemValue) Set(s string) error {
+	val, err := e.parse(s)
+	if err != nil {
+		return err
+	}
+	e.v.Set(val)
+	return nil
+}
+
+// mapValue is a flag.Value for map fields, it takes arguments of key=value
+// form, adding them to the map which is allocated on demand. For maps with
+// slice values, values of repeated keys are appended to the slice; for maps
+// with bool values, bare key is the same as key=true. The first call to Set
+// replaces the default value with a new map, so that a default map shared
+// with other values is not modified.
+type mapValue struct {
+	m     reflect.Value
+	parse func(string) (reflect.Value, error)
+	sep   string // separates key from value
+	multi bool   // whether map values are slices
+	pairs bool   // whether argument is a comma-separated list of pairs
+	set   bool   // whether Set was called
+}
+
+func (v *mapValue) String() string {
+	if !v.m.IsValid() || v.m.Len() == 0 {
+		return ""
+	}
+	keys := make([]string, 0, v.m.Len())
+	for _, k := range v.m.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	kt := v.m.Type().Key()
+	var pairs []string
+	for _, k := range keys {
+		val := v.m.MapIndex(reflect.ValueOf(k).Convert(kt))
+		if !v.multi {
+			pairs = append(pairs, k+v.sep+formatElem(val))
+			continue
+		}
+		for i := 0; i < val.Len(); i++ {
+			pairs = append(pairs, k+v.sep+formatElem(val.Index(i)))
+		}
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (v *mapValue) Set(s string) error {
+	args := []string{s}
+	if v.pairs {
+		args = strings.Split(s, ",")
+	}
+	keys := make([]reflect.Value, len(args))
+	vals := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		var err error
+		if keys[i], vals[i], err = v.parsePair(arg); err != nil {
+			return err
+		}
+	}
+	if !v.set {
+		v.m.Set(reflect.MakeMap(v.m.Type()))
+		v.set = true
+	}
+	for i, k := range keys {
+		val := vals[i]
+		if v.multi {
+			elems := v.m.MapIndex(k)
+			if !elems.IsValid() {
+				elems = reflect.MakeSlice(v.m.Type().Elem(), 0, 1)
+			}
+			val = reflect.Append(elems, val)
+		}
+		v.m.SetMapIndex(k, val)
+	}
+	return nil
+}
+
+func (v *mapValue) markDefault() { v.set = false }
+
+// parsePair parses a single key/value pair
+func (v *mapValue) parsePair(s string) (key, val reflect.Value, err error) {
+	i := strings.Index(s, v.sep)
+	if i < 0 && !v.multi && v.m.Type().Elem().Kind() == reflect.Bool {
+		// bare key of a bool map means key=true
+		s, i = s+v.sep+"true", len(s)
+	}
+	if i < 0 {
+		return key, val, fmt.Errorf("key%svalue form expected", v.sep)
+	}
+	k, arg := s[:i], s[i+len(v.sep):]
+	if v.multi || v.pairs {
+		k, arg = strings.TrimSpace(k), strings.TrimSpace(arg)
+	}
+	if val, err = v.parse(arg); err != nil {
+		return key, val, err
+	}
+	return reflect.ValueOf(k).Convert(v.m.Type().Key()), val, nil
+}
+
+// sliceValue is a flag.Value for slice fields. Each argument is split into
+// elements which are appended to the slice; the first call to Set replaces
+// the default value instead.
+type sliceValue struct {
+	s      reflect.Value
+	parse  func(string) (reflect.Value, error)
+	split  func(string) []string
+	sep    string // used to join elements by String
+	unique bool   // whether duplicate elements are skipped
+	sorted bool   // whether slice is kept sorted and deduplicated
+	maxLen int    // maximum number of elements, if positive
+	set    bool   // whether Set was called
+}
+
+func newSliceValue(v reflect.Value, parse func(string) (reflect.Value, error), opts tagOptions) *sliceValue {
+	sv := &sliceValue{
+		s:      v,
+		parse:  parse,
+		unique: opts.has("unique"),
+		sorted: opts.has("sorted-set"),
+	}
+	// value is validated by tagSpec.check
+	sv.maxLen, _ = strconv.Atoi(opts["maxlen"])
+	sv.split, sv.sep = splitFunc(opts)
+	return sv
+}
+
+// splitFunc returns function splitting argument into elements and separator
+// to join them back according to sep and fields options
+func splitFunc(opts tagOptions) (split func(string) []string, sep string) {
+	if opts.has("fields") {
+		return strings.Fields, " "
+	}
+	if expr, ok := opts["split"]; ok {
+		// expression is validated by tagSpec.check
+		re := regexp.MustCompile(expr)
+		return func(s string) []string { return re.Split(s, -1) }, ","
+	}
+	sep = ","
+	if s, ok := opts["sep"]; ok {
+		sep = s
+	}
+	return func(s string) []string { return strings.Split(s, sep) }, sep
+}
+
+func (v *sliceValue) String() string {
+	if !v.s.IsValid() || v.s.Len() == 0 {
+		return ""
+	}
+	elems := make([]string, v.s.Len())
+	for i := range elems {
+		elems[i] = formatElem(v.s.Index(i))
+	}
+	return strings.Join(elems, v.sep)
+}
+
+func (v *sliceValue) markDefault() { v.set = false }
+
+func (v *sliceValue) Set(s string) error {
+	out := v.s
+	if !v.set {
+		out = reflect.MakeSlice(v.s.Type(), 0, 0)
+	}
+	if s != "" {
+		for _, elem := range v.split(s) {
+			val, err := v.parse(elem)
+			if err != nil {
+				return err
+			}
+			if v.unique && contains(out, val) {
+				continue
+			}
+			out = reflect.Append(out, val)
+		}
+	}
+	if v.sorted {
+		out = sortedSet(out)
+	}
+	if v.maxLen > 0 && out.Len() > v.maxLen {
+		return fmt.Errorf("too many elements: %d, at most %d allowed", out.Len(), v.maxLen)
+	}
+	v.s.Set(out)
+	v.set = true
+	return nil
+}
+
+// contains reports whether slice s has element equal to val
+func contains(s, val reflect.Value) bool {
+	for i := 0; i < s.Len(); i++ {
+		if reflect.DeepEqual(s.Index(i).Interface(), val.Interface()) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedSet returns a copy of string or int slice s sorted in ascending order
+// with duplicates removed
+func sortedSet(s reflect.Value) reflect.Value {
+	if s.Type().Elem().Kind() == reflect.Int {
+		elems := make([]int, s.Len())
+		for i := range elems {
+			elems[i] = int(s.Index(i).Int())
+		}
+		sort.Ints(elems)
+		out := reflect.MakeSlice(s.Type(), 0, len(elems))
+		for i, elem := range elems {
+			if i > 0 && elem == elems[i-1] {
+				continue
+			}
+			out = reflect.Append(out, reflect.ValueOf(elem).Convert(s.Type().Elem()))
+		}
+		return out
+	}
+	elems := make([]string, s.Len())
+	for i := range elems {
+		elems[i] = s.Index(i).String()
+	}
+	sort.Strings(elems)
+	out := reflect.MakeSlice(s.Type(), 0, len(elems))
+	for i, elem := range elems {
+		if i > 0 && elem == elems[i-1] {
+			continue
+		}
+		out = reflect.Append(out, reflect.ValueOf(elem).Convert(s.Type().Elem()))
+	}
+	return out
+}
+
+// arrayValue is a flag.Value for array fields, argument must have exactly as
+// many elements as the array length.
+type arrayValue struct {
+	a     reflect.Value
+	parse func(string) (reflect.Value, error)
+	split func(string) []string
+	sep   string // used to join elements by String
+}
+
+func newArrayValue(v reflect.Value, parse func(string) (reflect.Value, error), opts tagOptions) *arrayValue {
+	av := &arrayValue{a: v, parse: parse}
+	av.split, av.sep = splitFunc(opts)
+	return av
+}
+
+func (v *arrayValue) String() string {
+	if !v.a.IsValid() {
+		return ""
+	}
+	elems := make([]string, v.a.Len())
+	for i := range elems {
+		elems[i] = formatElem(v.a.Index(i))
+	}
+	return strings.Join(elems, v.sep)
+}
+
+func (v *arrayValue) Set(s string) error {
+	elems := v.split(s)
+	if len(elems) != v.a.Len() {
+		return fmt.Errorf("%d elements expected, got %d", v.a.Len(), len(elems))
+	}
+	out := reflect.New(v.a.Type()).Elem()
+	for i, elem := range elems {
+		val, err := v.parse(elem)
+		if err != nil {
+			return err
+		}
+		out.Index(i).Set(val)
+	}
+	v.a.Set(out)
+	return nil
+}
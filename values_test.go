@@ -0,0 +1,1034 @@
+package autoflags
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFromFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(name, []byte("s3cr3t\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Token string `flag:"token,auth token,fromfile"`
+	}{Token: "default"}
+	DefineFlagSet(fs, &conf)
+	if err := fs.Parse([]string{"-token", name}); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Token != "s3cr3t" {
+		t.Fatalf("got %q, want %q", conf.Token, "s3cr3t")
+	}
+	if err := fs.Parse([]string{"-token", name + ".missing"}); err == nil {
+		t.Fatal("parsing should fail on missing file")
+	}
+}
+
+func TestBytesText(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Delim []byte `flag:"delim,,text"`
+	}{Delim: []byte("\t")}
+	DefineFlagSet(fs, &conf)
+	if got, want := fs.Lookup("delim").DefValue, `"\t"`; got != want {
+		t.Fatalf("default is %s, want %s", got, want)
+	}
+	if err := fs.Parse([]string{"-delim", `\r\n;`}); err != nil {
+		t.Fatal(err)
+	}
+	if string(conf.Delim) != "\r\n;" {
+		t.Fatalf("got %q, want %q", conf.Delim, "\r\n;")
+	}
+	for arg, want := range map[string]string{
+		`say "hi"`:   `say "hi"`,
+		`\"\t\xff é`: "\"\t\xff é",
+	} {
+		if err := fs.Set("delim", arg); err != nil || string(conf.Delim) != want {
+			t.Fatalf("setting %s: got %q, want %q (%v)", arg, conf.Delim, want, err)
+		}
+	}
+	if err := fs.Parse([]string{"-delim", `\q`}); err == nil {
+		t.Fatal("parsing should fail on invalid escape sequence")
+	}
+	err := DefineFlagSetStrict(flag.NewFlagSet("test", flag.ContinueOnError), &struct {
+		Delim string `flag:"delim,,text"`
+	}{})
+	if err == nil {
+		t.Fatal("text option on string field should fail")
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	setenv(t, map[string]string{"AUTOFLAGS_TEST_USER": "gopher"})
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Greeting string `flag:"greeting,,template"`
+	}{}
+	DefineFlagSet(fs, &conf)
+	if err := fs.Parse([]string{"-greeting", "Hello {{.AUTOFLAGS_TEST_USER}}"}); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Greeting != "Hello gopher" {
+		t.Fatalf("got %q, want %q", conf.Greeting, "Hello gopher")
+	}
+	for _, arg := range []string{"Hello {{.AUTOFLAGS_TEST_MISSING}}", "Hello {{"} {
+		if err := fs.Set("greeting", arg); err == nil {
+			t.Errorf("setting %q should fail", arg)
+		}
+	}
+}
+
+func TestHumanBool(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Color bool `flag:"color,,humanbool"`
+	}{}
+	DefineFlagSet(fs, &conf)
+	for _, tc := range []struct {
+		arg  string
+		want bool
+	}{{"yes", true}, {"OFF", false}, {"On", true}, {"0", false}, {"TRUE", true}} {
+		if err := fs.Set("color", tc.arg); err != nil {
+			t.Fatal(err)
+		}
+		if conf.Color != tc.want {
+			t.Errorf("%q: got %v, want %v", tc.arg, conf.Color, tc.want)
+		}
+	}
+	if err := fs.Parse([]string{"-color"}); err != nil || !conf.Color {
+		t.Fatalf("humanbool flag should still work without argument: %v", err)
+	}
+	if err := fs.Set("color", "maybe"); err == nil {
+		t.Fatal("setting invalid value should fail")
+	}
+}
+
+func TestInvert(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		DisableCache bool `flag:"cache,enable cache,invert"`
+		NoColor      bool `flag:"color,colorize output,invert"`
+	}{NoColor: true}
+	DefineFlagSet(fs, &conf)
+	if got := fs.Lookup("cache").DefValue; got != "true" {
+		t.Fatalf("-cache default is %q, want true", got)
+	}
+	if got := fs.Lookup("color").DefValue; got != "false" {
+		t.Fatalf("-color default is %q, want false", got)
+	}
+	if err := fs.Parse([]string{"-cache=false", "-color"}); err != nil {
+		t.Fatal(err)
+	}
+	if !conf.DisableCache || conf.NoColor {
+		t.Fatalf("unexpected result: %+v", conf)
+	}
+	var buf bytes.Buffer
+	fs.SetOutput(&buf)
+	PrintDefaults(fs)
+	want := "  -cache\n    \tenable cache (default true)\n  -color\n    \tcolorize output\n"
+	if buf.String() != want {
+		t.Fatalf("got usage %q, want %q", buf.String(), want)
+	}
+}
+
+func TestBits(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Perms uint `flag:"perms,,bits=read:1|write:2|exec:4"`
+	}{Perms: 1}
+	DefineFlagSet(fs, &conf)
+	if got := fs.Lookup("perms").DefValue; got != "read" {
+		t.Fatalf("unexpected default: %q", got)
+	}
+	if err := fs.Parse([]string{"-perms", "write, exec", "-perms", "write"}); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Perms != 6 {
+		t.Fatalf("got %d, want 6", conf.Perms)
+	}
+	if got := fs.Lookup("perms").Value.String(); got != "write,exec" {
+		t.Fatalf("String returned %q", got)
+	}
+	err := fs.Set("perms", "delete")
+	if err == nil || err.Error() != `unknown name "delete", valid ones are: read, write, exec` {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, c := range []interface{}{
+		&struct {
+			Perms int `flag:"perms,,bits=read:1"`
+		}{},
+		&struct {
+			Perms uint `flag:"perms,,bits=read"`
+		}{},
+	} {
+		if err := DefineFlagSetStrict(flag.NewFlagSet("test", flag.ContinueOnError), c); err == nil {
+			t.Errorf("DefineFlagSetStrict(%T) should fail", c)
+		}
+	}
+}
+
+func TestLevels(t *testing.T) {
+	type logLevel int8
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	conf := struct {
+		Level logLevel `flag:"log-level,,levels=debug:0|info:1|warn:2|error:3"`
+	}{Level: 1}
+	DefineFlagSet(fs, &conf)
+	if got := fs.Lookup("log-level").DefValue; got != "info" {
+		t.Fatalf("unexpected default: %q", got)
+	}
+	if err := fs.Parse([]string{"-log-level", "WARN"}); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Level != 2 {
+		t.Fatalf("unexpected level: %d", conf.Level)
+	}
+	if err := fs.Set("log-level", "7"); err != nil {
+		t.Fatal(err)
+	}
+	if got := fs.Lookup("log-level").Value.String(); got != "7" {
+		t.Fatalf("unexpected value: %q", got)
+	}
+	err := fs.Set("log-level", "trace")
+	if want := `unknown level "trace", valid ones are: debug, info, warn, error`; err == nil || err.Error() != want {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := DefineFlagSetStrict(fs, &struct {
+		Level uint `flag:"level,,levels=debug:0"`
+	}{}); err == nil {
+		t.Fatal("levels option on unsigned field should fail")
+	}
+}
+
+func TestCalendarValues(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Day    time.Weekday   `flag:"day"`
+		Month  time.Month     `flag:"month"`
+		Closed []time.Weekday `flag:"closed"`
+	}{Day: time.Friday, Month: time.March}
+	DefineFlagSet(fs, &conf)
+	if got := fs.Lookup("day").DefValue; got != "Friday" {
+		t.Fatalf("unexpected default: %q", got)
+	}
+	if err := fs.Parse([]string{"-day", "mon", "-month", "12", "-closed", "SATURDAY,sun"}); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Day != time.Monday || conf.Month != time.December {
+		t.Fatalf("unexpected result: %+v", conf)
+	}
+	if want := []time.Weekday{time.Saturday, time.Sunday}; !reflect.DeepEqual(conf.Closed, want) {
+		t.Fatalf("got %v, want %v", conf.Closed, want)
+	}
+	if got := fs.Lookup("month").Value.String(); got != "December" {
+		t.Fatalf("String returned %q", got)
+	}
+	for name, arg := range map[string]string{"day": "7", "month": "0", "closed": "someday"} {
+		if err := fs.Set(name, arg); err == nil {
+			t.Errorf("setting -%s to %q should fail", name, arg)
+		}
+	}
+	sunday := struct {
+		Day time.Weekday `flag:"day"`
+	}{Day: time.Sunday}
+	fs2 := flag.NewFlagSet("test", flag.ContinueOnError)
+	var buf bytes.Buffer
+	fs2.SetOutput(&buf)
+	DefineFlagSet(fs2, &sunday)
+	if got := fs2.Lookup("day").DefValue; got != "Sunday" {
+		t.Fatalf("unexpected default: %q", got)
+	}
+	PrintDefaults(fs2)
+	if !strings.Contains(buf.String(), "(default Sunday)") {
+		t.Fatalf("Sunday default is not shown:\n%s", buf.String())
+	}
+	err := fs.Set("day", "funday")
+	want := "unknown time.Weekday \"funday\", valid ones are: Sunday, Monday, Tuesday, Wednesday, Thursday, Friday, Saturday"
+	if err == nil || err.Error() != want {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestMapValue(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Limits map[string]int    `flag:"limit"`
+		Labels map[string]string `flag:"label"`
+	}{Labels: map[string]string{"env": "dev"}}
+	DefineFlagSet(fs, &conf)
+	if got := fs.Lookup("label").DefValue; got != "env=dev" {
+		t.Fatalf("unexpected default: %q", got)
+	}
+	args := []string{"-limit", "mem=8", "-limit", "cpu=4", "-label", "tier=web=1"}
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]int{"cpu": 4, "mem": 8}; !reflect.DeepEqual(conf.Limits, want) {
+		t.Fatalf("got %v, want %v", conf.Limits, want)
+	}
+	if want := map[string]string{"tier": "web=1"}; !reflect.DeepEqual(conf.Labels, want) {
+		t.Fatalf("got %v, want %v", conf.Labels, want)
+	}
+	if got := fs.Lookup("limit").Value.String(); got != "cpu=4,mem=8" {
+		t.Fatalf("unexpected String() result: %q", got)
+	}
+	for _, arg := range []string{"cpu", "cpu=four"} {
+		if err := fs.Set("limit", arg); err == nil {
+			t.Errorf("setting %q should fail", arg)
+		}
+	}
+}
+
+func TestMapDefaultReplaced(t *testing.T) {
+	defaults := map[string]string{"env": "dev"}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Labels map[string]string `flag:"label"`
+	}{Labels: defaults}
+	DefineFlagSet(fs, &conf)
+	if err := fs.Parse([]string{"-label", "tier=web", "-label", "env=prod"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"env": "prod", "tier": "web"}; !reflect.DeepEqual(conf.Labels, want) {
+		t.Fatalf("got %v, want %v", conf.Labels, want)
+	}
+	if want := map[string]string{"env": "dev"}; !reflect.DeepEqual(defaults, want) {
+		t.Fatalf("default map modified: %v", defaults)
+	}
+}
+
+func TestMapBool(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Features map[string]bool `flag:"feature"`
+	}{}
+	DefineFlagSet(fs, &conf)
+	if err := fs.Parse([]string{"-feature", "b=false", "-feature", "a", "-feature", "c=true"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]bool{"a": true, "b": false, "c": true}; !reflect.DeepEqual(conf.Features, want) {
+		t.Fatalf("got %v, want %v", conf.Features, want)
+	}
+	if got := fs.Lookup("feature").Value.String(); got != "a=true,b=false,c=true" {
+		t.Fatalf("unexpected value: %q", got)
+	}
+	if err := fs.Set("feature", "d=maybe"); err == nil {
+		t.Fatal("setting invalid bool should fail")
+	}
+}
+
+func TestMapPairs(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Timeouts map[string]time.Duration `flag:"timeouts,,pairs"`
+	}{Timeouts: map[string]time.Duration{"slow": 2 * time.Second, "fast": 100 * time.Millisecond}}
+	DefineFlagSet(fs, &conf)
+	if got, want := fs.Lookup("timeouts").DefValue, "fast=100ms,slow=2s"; got != want {
+		t.Fatalf("default is %q, want %q", got, want)
+	}
+	if err := fs.Parse([]string{"-timeouts", "fast=50ms, idle=1m", "-timeouts", "slow=5s"}); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]time.Duration{
+		"fast": 50 * time.Millisecond,
+		"slow": 5 * time.Second,
+		"idle": time.Minute,
+	}
+	if !reflect.DeepEqual(conf.Timeouts, want) {
+		t.Fatalf("got %v, want %v", conf.Timeouts, want)
+	}
+	for _, arg := range []string{"fast=1s,slow", "fast=soon"} {
+		if err := fs.Set("timeouts", arg); err == nil {
+			t.Errorf("setting %q should fail", arg)
+		}
+	}
+	if !reflect.DeepEqual(conf.Timeouts, want) {
+		t.Fatalf("failed Set modified the map: %v", conf.Timeouts)
+	}
+}
+
+func TestSliceValue(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Names []string `flag:"name"`
+		IDs   []int    `flag:"id,,sep=;"`
+		Words []string `flag:"words,,fields"`
+		Keep  []string `flag:"keep"`
+	}{Names: []string{"default"}, Keep: []string{"a", "b"}}
+	DefineFlagSet(fs, &conf)
+	if got := fs.Lookup("keep").DefValue; got != "a,b" {
+		t.Fatalf("unexpected default: %q", got)
+	}
+	args := []string{
+		"-name", "x,y", "-name", "z",
+		"-id", "1;2", "-id", "3",
+		"-words", " alpha  beta\tgamma ", "-words", "delta",
+	}
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"x", "y", "z"}; !reflect.DeepEqual(conf.Names, want) {
+		t.Fatalf("got %q, want %q", conf.Names, want)
+	}
+	if want := []int{1, 2, 3}; !reflect.DeepEqual(conf.IDs, want) {
+		t.Fatalf("got %v, want %v", conf.IDs, want)
+	}
+	if want := []string{"alpha", "beta", "gamma", "delta"}; !reflect.DeepEqual(conf.Words, want) {
+		t.Fatalf("got %q, want %q", conf.Words, want)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(conf.Keep, want) {
+		t.Fatalf("got %q, want %q", conf.Keep, want)
+	}
+	if err := fs.Set("id", "4;x"); err == nil {
+		t.Fatal("setting invalid element should fail")
+	}
+}
+
+func TestUintSlice(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Workers []uint   `flag:"workers"`
+		Ports   []uint64 `flag:"ports"`
+	}{Workers: []uint{7}}
+	DefineFlagSet(fs, &conf)
+	if got := fs.Lookup("workers").DefValue; got != "7" {
+		t.Fatalf("unexpected default: %q", got)
+	}
+	if err := fs.Parse([]string{"-workers", "1,2", "-workers", "3", "-ports", "18446744073709551615"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint{1, 2, 3}; !reflect.DeepEqual(conf.Workers, want) {
+		t.Fatalf("got %v, want %v", conf.Workers, want)
+	}
+	if want := []uint64{1<<64 - 1}; !reflect.DeepEqual(conf.Ports, want) {
+		t.Fatalf("got %v, want %v", conf.Ports, want)
+	}
+	for _, arg := range []string{"-1", "18446744073709551616", "x"} {
+		if err := fs.Set("ports", arg); err == nil {
+			t.Errorf("setting %q should fail", arg)
+		}
+	}
+}
+
+func TestPairSlice(t *testing.T) {
+	type step struct{ Key, Value string }
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Steps []step `flag:"step"`
+	}{Steps: []step{{"resize", "50%"}}}
+	DefineFlagSet(fs, &conf)
+	if got := fs.Lookup("step").DefValue; got != "resize=50%" {
+		t.Fatalf("unexpected default: %q", got)
+	}
+	if err := fs.Parse([]string{"-step", "b=2", "-step", "a=1", "-step", "b=x=3"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []step{{"b", "2"}, {"a", "1"}, {"b", "x=3"}}; !reflect.DeepEqual(conf.Steps, want) {
+		t.Fatalf("got %v, want %v", conf.Steps, want)
+	}
+	if err := fs.Set("step", "a"); err == nil {
+		t.Fatal("setting value without = should fail")
+	}
+}
+
+func TestBoolSlice(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Stages []bool `flag:"stage"`
+	}{Stages: []bool{true, true}}
+	DefineFlagSet(fs, &conf)
+	if got := fs.Lookup("stage").DefValue; got != "true,true" {
+		t.Fatalf("unexpected default: %q", got)
+	}
+	if err := fs.Parse([]string{"-stage", "true", "-stage", "false,true"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []bool{true, false, true}; !reflect.DeepEqual(conf.Stages, want) {
+		t.Fatalf("got %v, want %v", conf.Stages, want)
+	}
+	if err := fs.Parse([]string{"-stage", "-other"}); err == nil {
+		t.Fatal("bool slice flag should require an argument")
+	}
+}
+
+func TestSliceDefaultReplaced(t *testing.T) {
+	_, net1, _ := net.ParseCIDR("10.0.0.0/8")
+	_, net2, _ := net.ParseCIDR("192.168.0.0/16")
+	_, net3, _ := net.ParseCIDR("172.16.0.0/12")
+	type conf struct {
+		Strings []string    `flag:"s"`
+		Ints    []int       `flag:"i"`
+		Floats  []float64   `flag:"f"`
+		Nets    []net.IPNet `flag:"n"`
+	}
+	newConf := func() *conf {
+		return &conf{
+			Strings: []string{"default"},
+			Ints:    []int{1},
+			Floats:  []float64{0.5},
+			Nets:    []net.IPNet{*net1},
+		}
+	}
+	for _, tc := range []struct {
+		desc string
+		args []string
+		want *conf
+	}{
+		{"no flags", nil, newConf()},
+		{"one flag", []string{"-s", "a", "-i", "2", "-f", "1.5", "-n", "192.168.0.0/16"},
+			&conf{[]string{"a"}, []int{2}, []float64{1.5}, []net.IPNet{*net2}}},
+		{"multiple flags", []string{
+			"-s", "a", "-s", "b,c",
+			"-i", "2", "-i", "3,4",
+			"-f", "1.5", "-f", "2.5",
+			"-n", "192.168.0.0/16", "-n", "172.16.0.0/12",
+		}, &conf{
+			[]string{"a", "b", "c"},
+			[]int{2, 3, 4},
+			[]float64{1.5, 2.5},
+			[]net.IPNet{*net2, *net3},
+		}},
+	} {
+		c := newConf()
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		DefineFlagSet(fs, c)
+		if err := fs.Parse(tc.args); err != nil {
+			t.Fatalf("%s: %v", tc.desc, err)
+		}
+		if !reflect.DeepEqual(c, tc.want) {
+			t.Errorf("%s: got %+v, want %+v", tc.desc, c, tc.want)
+		}
+	}
+}
+
+func TestSliceSplitRegexp(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Tokens []string `flag:"tokens,,split=\\s*[,;]\\s*"`
+	}{}
+	DefineFlagSet(fs, &conf)
+	if err := fs.Parse([]string{"-tokens", "a , b;c  ;d"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "b", "c", "d"}; !reflect.DeepEqual(conf.Tokens, want) {
+		t.Fatalf("got %q, want %q", conf.Tokens, want)
+	}
+	for _, c := range []interface{}{
+		&struct {
+			Tokens []string `flag:"tokens,,split=["`
+		}{},
+		&struct {
+			Tokens []string `flag:"tokens,,split=;,fields"`
+		}{},
+	} {
+		if err := DefineFlagSetStrict(flag.NewFlagSet("test", flag.ContinueOnError), c); err == nil {
+			t.Errorf("DefineFlagSetStrict(%T) should fail", c)
+		}
+	}
+}
+
+func TestSliceOptionsConflict(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Words []string `flag:"words,,fields,sep=;"`
+	}{}
+	defer func() {
+		if x := recover(); x == nil {
+			t.Fatal("should have panicked on conflicting options")
+		}
+	}()
+	DefineFlagSet(fs, &conf)
+}
+
+func TestLocationValue(t *testing.T) {
+	if _, err := time.LoadLocation("America/New_York"); err != nil {
+		t.Skipf("time zone database is not available: %v", err)
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Zone  *time.Location `flag:"tz"`
+		Local *time.Location `flag:"local,,emptyutc"`
+	}{Zone: time.UTC}
+	DefineFlagSet(fs, &conf)
+	if got := fs.Lookup("tz").DefValue; got != "UTC" {
+		t.Fatalf("unexpected default: %q", got)
+	}
+	if err := fs.Parse([]string{"-tz", "America/New_York", "-local", ""}); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Zone.String() != "America/New_York" || conf.Local != time.UTC {
+		t.Fatalf("unexpected values: %v, %v", conf.Zone, conf.Local)
+	}
+	for _, arg := range []string{"", "Mars/Olympus_Mons"} {
+		if err := fs.Set("tz", arg); err == nil {
+			t.Errorf("setting %q should fail", arg)
+		}
+	}
+}
+
+func TestArrayValue(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Coords [3]float64 `flag:"coord"`
+		Pair   [2]string  `flag:"pair,,sep=:"`
+	}{Pair: [2]string{"a", "b"}}
+	DefineFlagSet(fs, &conf)
+	if got := fs.Lookup("pair").DefValue; got != "a:b" {
+		t.Fatalf("unexpected default: %q", got)
+	}
+	if err := fs.Parse([]string{"-coord", "1.0,2.5,-3"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := [3]float64{1, 2.5, -3}; conf.Coords != want {
+		t.Fatalf("got %v, want %v", conf.Coords, want)
+	}
+	for _, arg := range []string{"1,2", "1,2,3,4", "1,2,x"} {
+		if err := fs.Set("coord", arg); err == nil {
+			t.Errorf("setting %q should fail", arg)
+		}
+	}
+	if want := [3]float64{1, 2.5, -3}; conf.Coords != want {
+		t.Fatalf("failed Set should not modify value, got %v", conf.Coords)
+	}
+}
+
+func TestUniqueUncomparable(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Nets []net.IPNet        `flag:"net,,unique"`
+		MACs []net.HardwareAddr `flag:"mac,,unique"`
+	}{}
+	DefineFlagSet(fs, &conf)
+	args := []string{
+		"-net", "10.0.0.0/8,192.168.0.0/16", "-net", "10.0.0.0/8",
+		"-mac", "00:11:22:33:44:55", "-mac", "00-11-22-33-44-55",
+	}
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	if len(conf.Nets) != 2 || len(conf.MACs) != 1 {
+		t.Fatalf("duplicates not removed: %v, %v", conf.Nets, conf.MACs)
+	}
+}
+
+func TestOneOf(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Mode     string   `flag:"mode,output mode,oneof=text|json"`
+		Features []string `flag:"features,,oneof=a|b|c,unique"`
+	}{Mode: "text"}
+	DefineFlagSet(fs, &conf)
+	if got := fs.Lookup("mode").Usage; got != "output mode (one of: text, json)" {
+		t.Fatalf("unexpected usage: %q", got)
+	}
+	if err := fs.Parse([]string{"-mode", "json", "-features", "b,a", "-features", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"b", "a"}; conf.Mode != "json" || !reflect.DeepEqual(conf.Features, want) {
+		t.Fatalf("unexpected values: %+v", conf)
+	}
+	if err := fs.Set("mode", "yaml"); err == nil || conf.Mode != "json" {
+		t.Fatal("setting value not listed in oneof should fail")
+	}
+	if err := fs.Set("features", "a,d"); err == nil {
+		t.Fatal("setting element not listed in oneof should fail")
+	}
+}
+
+func TestNonEmpty(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Name string `flag:"name,,nonempty"`
+	}{}
+	DefineFlagSet(fs, &conf)
+	for _, arg := range []string{"", " \t"} {
+		if err := fs.Set("name", arg); err == nil {
+			t.Errorf("setting %q should fail", arg)
+		}
+	}
+	if err := fs.Set("name", " x "); err != nil || conf.Name != " x " {
+		t.Fatalf("unexpected result: %q, %v", conf.Name, err)
+	}
+}
+
+func TestPattern(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Host  string   `flag:"hostname,,pattern=^[a-z0-9.-]+$"`
+		Hosts []string `flag:"hosts,,pattern=^[a-z]+$"`
+	}{Host: "localhost"}
+	if err := DefineFlagSetStrict(fs, &conf); err != nil {
+		t.Fatal(err)
+	}
+	err := fs.Set("hostname", "Example.COM")
+	if err == nil || conf.Host != "localhost" {
+		t.Fatal("setting value not matching pattern should fail")
+	}
+	if !strings.Contains(err.Error(), "^[a-z0-9.-]+$") {
+		t.Fatalf("error should mention pattern: %v", err)
+	}
+	if err := fs.Set("hostname", "example.com"); err != nil || conf.Host != "example.com" {
+		t.Fatalf("unexpected result: %q, %v", conf.Host, err)
+	}
+	if err := fs.Set("hosts", "a,b1"); err == nil {
+		t.Fatal("setting element not matching pattern should fail")
+	}
+
+	for _, config := range []interface{}{
+		&struct {
+			Host string `flag:"hostname,,pattern=^[a-z]+$"`
+		}{Host: "Bad"},
+		&struct {
+			Host string `flag:"hostname,,pattern=^[a-z]+$,default=Bad"`
+		}{},
+		&struct {
+			Host string `flag:"hostname,,pattern=[a-z"`
+		}{},
+		&struct {
+			Port int `flag:"port,,pattern=^[0-9]+$"`
+		}{},
+	} {
+		if err := DefineFlagSetStrict(flag.NewFlagSet("test", flag.ContinueOnError), config); err == nil {
+			t.Errorf("defining flags for %+v should fail", config)
+		}
+	}
+}
+
+func TestSortedSet(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Regions []string `flag:"region,,sorted-set"`
+	}{Regions: []string{"default"}}
+	DefineFlagSet(fs, &conf)
+	if err := fs.Parse([]string{"-region", "us,eu", "-region", "ap,us"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"ap", "eu", "us"}; !reflect.DeepEqual(conf.Regions, want) {
+		t.Fatalf("got %q, want %q", conf.Regions, want)
+	}
+
+	ids := struct {
+		IDs []int `flag:"ids,,sorted-set"`
+	}{IDs: []int{5}}
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	DefineFlagSet(fs, &ids)
+	if err := fs.Parse([]string{"-ids", "3,1,2,1", "-ids", "10,2"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{1, 2, 3, 10}; !reflect.DeepEqual(ids.IDs, want) {
+		t.Fatalf("got %v, want %v", ids.IDs, want)
+	}
+	if got := fs.Lookup("ids").Value.String(); got != "1,2,3,10" {
+		t.Fatalf("unexpected value: %q", got)
+	}
+	if err := fs.Set("ids", "4,x"); err == nil {
+		t.Fatal("setting invalid integer should fail")
+	}
+}
+
+func TestSliceMaxLen(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	conf := struct {
+		Peers []int `flag:"peer,,maxlen=3"`
+	}{}
+	DefineFlagSet(fs, &conf)
+	if err := fs.Parse([]string{"-peer", "1,2", "-peer", "3"}); err != nil {
+		t.Fatal(err)
+	}
+	err := fs.Set("peer", "4")
+	if err == nil || !strings.Contains(err.Error(), "too many elements: 4, at most 3 allowed") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []int{1, 2, 3}; !reflect.DeepEqual(conf.Peers, want) {
+		t.Fatalf("got %v, want %v", conf.Peers, want)
+	}
+	for _, c := range []interface{}{
+		&struct {
+			Peers []int `flag:"peer,,maxlen=0"`
+		}{},
+		&struct {
+			Peers []int `flag:"peer,,minlen=x"`
+		}{},
+		&struct {
+			Peer int `flag:"peer,,maxlen=1"`
+		}{},
+	} {
+		if err := DefineFlagSetStrict(flag.NewFlagSet("test", flag.ContinueOnError), c); err == nil {
+			t.Errorf("%T: expected error", c)
+		}
+	}
+}
+
+func TestIPNetValue(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	mustParse := func(s string) net.IPNet {
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return *n
+	}
+	conf := struct {
+		Net  net.IPNet   `flag:"net"`
+		CIDR []net.IPNet `flag:"cidr"`
+	}{CIDR: []net.IPNet{mustParse("127.0.0.0/8")}}
+	DefineFlagSet(fs, &conf)
+	if got := fs.Lookup("net").DefValue; got != "" {
+		t.Fatalf("unexpected default: %q", got)
+	}
+	if got := fs.Lookup("cidr").DefValue; got != "127.0.0.0/8" {
+		t.Fatalf("unexpected default: %q", got)
+	}
+	args := []string{"-net", "10.1.2.3/8", "-cidr", "10.0.0.0/8,192.168.0.0/16", "-cidr", "fd00::/8"}
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	if got := fs.Lookup("net").Value.String(); got != "10.0.0.0/8" {
+		t.Fatalf("unexpected net: %q", got)
+	}
+	if got := fs.Lookup("cidr").Value.String(); got != "10.0.0.0/8,192.168.0.0/16,fd00::/8" {
+		t.Fatalf("unexpected networks: %q", got)
+	}
+	if err := fs.Set("cidr", "10.0.0.0/33"); err == nil {
+		t.Fatal("setting malformed CIDR should fail")
+	}
+}
+
+func TestHardwareAddrValue(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	mustParse := func(s string) net.HardwareAddr {
+		addr, err := net.ParseMAC(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return addr
+	}
+	conf := struct {
+		MAC  net.HardwareAddr   `flag:"mac"`
+		MACs []net.HardwareAddr `flag:"macs"`
+	}{MACs: []net.HardwareAddr{mustParse("00:00:5e:00:53:01")}}
+	DefineFlagSet(fs, &conf)
+	if got := fs.Lookup("mac").DefValue; got != "" {
+		t.Fatalf("unexpected default: %q", got)
+	}
+	if got := fs.Lookup("macs").DefValue; got != "00:00:5e:00:53:01" {
+		t.Fatalf("unexpected default: %q", got)
+	}
+	args := []string{"-mac", "00-11-22-33-44-55", "-macs", "00:11:22:33:44:55,66:77:88:99:aa:bb", "-macs", "0011.2233.4466"}
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	if got := conf.MAC.String(); got != "00:11:22:33:44:55" {
+		t.Fatalf("unexpected address: %q", got)
+	}
+	if got := fs.Lookup("macs").Value.String(); got != "00:11:22:33:44:55,66:77:88:99:aa:bb,00:11:22:33:44:66" {
+		t.Fatalf("unexpected addresses: %q", got)
+	}
+	if err := fs.Set("macs", "00:11:22:33:44:77,bad"); err == nil {
+		t.Fatal("setting malformed address should fail")
+	}
+	if len(conf.MACs) != 3 {
+		t.Fatalf("failed Set modified the slice: %v", conf.MACs)
+	}
+}
+
+func TestDefaultUnit(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Timeout time.Duration `flag:"timeout,,defaultunit=s"`
+	}{Timeout: time.Second}
+	DefineFlagSet(fs, &conf)
+	for _, tc := range []struct {
+		arg  string
+		want time.Duration
+	}{
+		{"30", 30 * time.Second},
+		{"-5", -5 * time.Second},
+		{"30ms", 30 * time.Millisecond},
+		{"1.5", 0},
+	} {
+		err := fs.Set("timeout", tc.arg)
+		if tc.want == 0 {
+			if err == nil {
+				t.Errorf("setting %q should fail", tc.arg)
+			}
+			continue
+		}
+		if err != nil || conf.Timeout != tc.want {
+			t.Errorf("%q: got %v (%v), want %v", tc.arg, conf.Timeout, err, tc.want)
+		}
+	}
+}
+
+func TestMapSliceValue(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Headers map[string][]string `flag:"header"`
+		Ports   map[string][]int    `flag:"port,,kvsep=="`
+	}{}
+	DefineFlagSet(fs, &conf)
+	args := []string{
+		"-header", "Accept: a", "-header", "Accept: b", "-header", "Host:example.com",
+		"-port", "http=80", "-port", "http=8080",
+	}
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{"Accept": {"a", "b"}, "Host": {"example.com"}}
+	if !reflect.DeepEqual(conf.Headers, want) {
+		t.Fatalf("got %q, want %q", conf.Headers, want)
+	}
+	if want := map[string][]int{"http": {80, 8080}}; !reflect.DeepEqual(conf.Ports, want) {
+		t.Fatalf("got %v, want %v", conf.Ports, want)
+	}
+	if got := fs.Lookup("header").Value.String(); got != "Accept:a,Accept:b,Host:example.com" {
+		t.Fatalf("unexpected String() result: %q", got)
+	}
+	if err := fs.Set("header", "Accept"); err == nil {
+		t.Fatal("setting value without separator should fail")
+	}
+}
+
+// textLevel implements encoding.TextMarshaler and encoding.TextUnmarshaler,
+// but not fmt.Stringer
+type textLevel int
+
+func (l textLevel) MarshalText() ([]byte, error) {
+	switch l {
+	case 0:
+		return []byte("low"), nil
+	case 1:
+		return []byte("high"), nil
+	}
+	return nil, errors.New("invalid level")
+}
+
+func (l *textLevel) UnmarshalText(b []byte) error {
+	switch string(b) {
+	case "low":
+		*l = 0
+	case "high":
+		*l = 1
+	default:
+		return errors.New("unknown level")
+	}
+	return nil
+}
+
+func TestTextValue(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Level textLevel `flag:"level"`
+		Since time.Time `flag:"since"`
+		IP    net.IP    `flag:"ip"`
+	}{Level: 1, Since: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)}
+	DefineFlagSet(fs, &conf)
+	if got := fs.Lookup("level").DefValue; got != "high" {
+		t.Fatalf("unexpected default: %q", got)
+	}
+	if got := fs.Lookup("since").DefValue; got != "2020-01-02T03:04:05Z" {
+		t.Fatalf("unexpected default: %q", got)
+	}
+	if err := fs.Parse([]string{"-level", "low", "-ip", "192.0.2.1"}); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Level != 0 || !conf.IP.Equal(net.IPv4(192, 0, 2, 1)) {
+		t.Fatalf("unexpected values: %+v", conf)
+	}
+	if err := fs.Set("level", "medium"); err == nil {
+		t.Fatal("setting invalid value should fail")
+	}
+	conf.Level = 5
+	if got := fs.Lookup("level").Value.String(); got != "5" {
+		t.Fatalf("String() should fall back to %%v, got %q", got)
+	}
+}
+
+func TestOneOfFloat(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Ratio float64 `flag:"ratio,,oneof=0.5|1.0|2.0"`
+		Scale float64 `flag:"scale,,oneof=1|3,epsilon=0.1"`
+	}{Ratio: 1, Scale: 1}
+	DefineFlagSet(fs, &conf)
+	if err := fs.Parse([]string{"-ratio", "2", "-scale", "2.95"}); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Ratio != 2 || conf.Scale != 2.95 {
+		t.Fatalf("unexpected values: %+v", conf)
+	}
+	for _, arg := range []string{"0.6", "x"} {
+		if err := fs.Set("ratio", arg); err == nil {
+			t.Errorf("setting %q should fail", arg)
+		}
+	}
+	err := DefineFlagSetStrict(flag.NewFlagSet("test", flag.ContinueOnError), &struct {
+		Ratio float64 `flag:"ratio,,oneof=0.5|2.0"`
+	}{Ratio: 1})
+	if err == nil {
+		t.Fatal("default value not listed in oneof should fail")
+	}
+}
+
+func TestRawJSON(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := struct {
+		Extra json.RawMessage `flag:"extra"`
+	}{Extra: json.RawMessage(`{}`)}
+	DefineFlagSet(fs, &conf)
+	if got := fs.Lookup("extra").DefValue; got != "{}" {
+		t.Fatalf("unexpected default: %q", got)
+	}
+	if err := fs.Parse([]string{"-extra", `{"a": [1, 2]}`}); err != nil {
+		t.Fatal(err)
+	}
+	if string(conf.Extra) != `{"a": [1, 2]}` {
+		t.Fatalf("unexpected value: %s", conf.Extra)
+	}
+	if err := fs.Set("extra", `{"a":`); err == nil {
+		t.Fatal("setting malformed JSON should fail")
+	}
+	if string(conf.Extra) != `{"a": [1, 2]}` {
+		t.Fatalf("failed Set modified the value: %s", conf.Extra)
+	}
+}
+
+func TestOnce(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	conf := struct {
+		Config string `flag:"config,,once,short=c"`
+		Name   string `flag:"name"`
+	}{}
+	DefineFlagSet(fs, &conf)
+	if err := ApplyDefaults(fs, strings.NewReader(`{"config": "default.conf"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Parse([]string{"-config", "a.conf", "-name", "x", "-name", "y"}); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Config != "a.conf" || conf.Name != "y" {
+		t.Fatalf("unexpected result: %+v", conf)
+	}
+	err := fs.Set("c", "b.conf")
+	if err == nil || err.Error() != "flag -config can only be set once" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if conf.Config != "a.conf" {
+		t.Fatalf("repeated flag changed the value: %q", conf.Config)
+	}
+}